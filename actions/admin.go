@@ -0,0 +1,136 @@
+package actions
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	refreshStatusAccepted  = "accepted"
+	refreshStatusSucceeded = "succeeded"
+	refreshStatusFailed    = "failed"
+)
+
+// RefreshStatus is the JSON body served by the refresh handler.
+type RefreshStatus struct {
+	Status    string `json:"status"`
+	Repo      string `json:"repo,omitempty"`
+	Coalesced bool   `json:"coalesced,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// RefreshHandler triggers a refresh on POST requests carrying token as a bearer token.
+// The repo query parameter (owner/repo) scopes the refresh to a single repository.
+// By default the refresh runs in the background, wait=true holds the response until it is done and reports its outcome.
+func (c *UsageCollector) RefreshHandler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !validBearerToken(req, token) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var (
+			query       = req.URL.Query()
+			fullName    = query.Get("repo")
+			owner, repo string
+			wait        bool
+			err         error
+		)
+
+		if fullName != "" {
+			var ok bool
+
+			owner, repo, ok = strings.Cut(fullName, "/")
+			if !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+				writeRefreshStatus(w, http.StatusBadRequest, RefreshStatus{
+					Status: refreshStatusFailed,
+					Repo:   fullName,
+					Error:  "repo must be formatted as owner/repo",
+				})
+				return
+			}
+		}
+
+		if rawWait := query.Get("wait"); rawWait != "" {
+			wait, err = strconv.ParseBool(rawWait)
+			if err != nil {
+				writeRefreshStatus(w, http.StatusBadRequest, RefreshStatus{
+					Status: refreshStatusFailed,
+					Repo:   fullName,
+					Error:  "wait must be a boolean",
+				})
+				return
+			}
+		}
+
+		result := c.TriggerRefresh(owner, repo)
+
+		if !wait {
+			writeRefreshStatus(w, http.StatusAccepted, RefreshStatus{
+				Status: refreshStatusAccepted,
+				Repo:   fullName,
+			})
+			return
+		}
+
+		select {
+		case <-req.Context().Done():
+			// The client is gone, the refresh keeps going anyway.
+			return
+		case res := <-result:
+			status := RefreshStatus{
+				Status:    refreshStatusSucceeded,
+				Repo:      fullName,
+				Coalesced: res.Coalesced,
+			}
+
+			if res.Err == nil {
+				writeRefreshStatus(w, http.StatusOK, status)
+				return
+			}
+
+			status.Status = refreshStatusFailed
+			status.Error = res.Err.Error()
+
+			writeRefreshStatus(w, refreshErrorCode(res.Err), status)
+		}
+	})
+}
+
+func validBearerToken(req *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+func refreshErrorCode(err error) int {
+	switch {
+	case errors.Is(err, ErrUnknownOwner), errors.Is(err, ErrRepoNotCollected):
+		return http.StatusNotFound
+	case errors.Is(err, errNoUsageData):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+func writeRefreshStatus(w http.ResponseWriter, code int, status RefreshStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	_ = json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,406 @@
+package actions_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jlevesy/workflows-exporter/actions"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+const adminToken = "t0k3n"
+
+type repoUsageFetcher struct {
+	usageFetcherFunc
+
+	fetchRepo func(ctx context.Context, owner, repo string) (*actions.Usage, error)
+}
+
+func (f repoUsageFetcher) FetchRepo(ctx context.Context, owner, repo string) (*actions.Usage, error) {
+	return f.fetchRepo(ctx, owner, repo)
+}
+
+func TestUsageCollector_RefreshHandler(t *testing.T) {
+	for _, testCase := range []struct {
+		desc        string
+		method      string
+		token       string
+		query       string
+		wantCode    int
+		wantStatus  actions.RefreshStatus
+		wantFetches int64
+		wantMetrics string
+	}{
+		{
+			desc:     "rejects other methods",
+			method:   http.MethodGet,
+			token:    adminToken,
+			wantCode: http.StatusMethodNotAllowed,
+		},
+		{
+			desc:     "rejects invalid tokens",
+			method:   http.MethodPost,
+			token:    "not-the-token",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			desc:     "rejects invalid repos",
+			method:   http.MethodPost,
+			token:    adminToken,
+			query:    "repo=repo-A",
+			wantCode: http.StatusBadRequest,
+			wantStatus: actions.RefreshStatus{
+				Status: "failed",
+				Repo:   "repo-A",
+				Error:  "repo must be formatted as owner/repo",
+			},
+		},
+		{
+			desc:     "refreshes in the background",
+			method:   http.MethodPost,
+			token:    adminToken,
+			wantCode: http.StatusAccepted,
+			wantStatus: actions.RefreshStatus{
+				Status: "accepted",
+			},
+		},
+		{
+			desc:     "refreshes everything",
+			method:   http.MethodPost,
+			token:    adminToken,
+			query:    "wait=true",
+			wantCode: http.StatusOK,
+			wantStatus: actions.RefreshStatus{
+				Status: "succeeded",
+			},
+			wantFetches: 2,
+		},
+		{
+			desc:     "refreshes a single repo",
+			method:   http.MethodPost,
+			token:    adminToken,
+			query:    "repo=totocorp/repo-A&wait=true",
+			wantCode: http.StatusOK,
+			wantStatus: actions.RefreshStatus{
+				Status: "succeeded",
+				Repo:   "totocorp/repo-A",
+			},
+			wantFetches: 1,
+			wantMetrics: `
+# HELP github_actions_workflow_billable_time_seconds Billable time for a repo, per workflow and platform
+# TYPE github_actions_workflow_billable_time_seconds gauge
+github_actions_workflow_billable_time_seconds{owner="totocorp",platform="UBUNTU",repo="repo-A",workflow="build",workflow_id="1"} 45
+github_actions_workflow_billable_time_seconds{owner="totocorp",platform="UBUNTU",repo="repo-B",workflow="build",workflow_id="2"} 15
+`,
+		},
+		{
+			desc:     "reports unknown repos",
+			method:   http.MethodPost,
+			token:    adminToken,
+			query:    "repo=acme/repo-A&wait=true",
+			wantCode: http.StatusNotFound,
+			wantStatus: actions.RefreshStatus{
+				Status: "failed",
+				Repo:   "acme/repo-A",
+				Error:  `unknown owner: "acme"`,
+			},
+			wantFetches: 1,
+		},
+	} {
+		t.Run(testCase.desc, func(t *testing.T) {
+			var (
+				fetches atomic.Int64
+				fetcher = repoUsageFetcher{
+					usageFetcherFunc: func(context.Context) (*actions.Usage, error) {
+						fetches.Add(1)
+
+						return &actions.Usage{
+							ActiveRepos: 2,
+							Workflows: []actions.WorkflowUsage{
+								{
+									Owner:        "totocorp",
+									Repo:         "repo-A",
+									Workflow:     "build",
+									ID:           1,
+									BillableTime: map[string]time.Duration{"UBUNTU": 15 * time.Second},
+								},
+								{
+									Owner:        "totocorp",
+									Repo:         "repo-B",
+									Workflow:     "build",
+									ID:           2,
+									BillableTime: map[string]time.Duration{"UBUNTU": 15 * time.Second},
+								},
+							},
+						}, nil
+					},
+					fetchRepo: func(_ context.Context, owner, repo string) (*actions.Usage, error) {
+						if owner != "totocorp" {
+							return nil, fmt.Errorf("%w: %q", actions.ErrUnknownOwner, owner)
+						}
+
+						return &actions.Usage{
+							Workflows: []actions.WorkflowUsage{
+								{
+									Owner:        owner,
+									Repo:         repo,
+									Workflow:     "build",
+									ID:           1,
+									BillableTime: map[string]time.Duration{"UBUNTU": 45 * time.Second},
+								},
+							},
+						}, nil
+					},
+				}
+				collector = actions.NewUsageCollector(
+					fetcher,
+					zaptest.NewLogger(t),
+					10*time.Minute,
+					actions.WithNowFunc(fixedNow(now)),
+					actions.WithSinceFunc(fixedSince(time.Second)),
+				)
+				registry = prometheus.NewRegistry()
+			)
+
+			defer collector.Close()
+
+			err := registry.Register(collector)
+			require.NoError(t, err)
+
+			<-collector.Ready()
+
+			req := httptest.NewRequest(testCase.method, "/admin/refresh?"+testCase.query, nil)
+			req.Header.Set("Authorization", "Bearer "+testCase.token)
+
+			rec := httptest.NewRecorder()
+			collector.RefreshHandler(adminToken).ServeHTTP(rec, req)
+
+			assert.Equal(t, testCase.wantCode, rec.Code)
+
+			if testCase.wantStatus.Status != "" {
+				var status actions.RefreshStatus
+				err = json.Unmarshal(rec.Body.Bytes(), &status)
+				require.NoError(t, err)
+
+				assert.Equal(t, testCase.wantStatus, status)
+			}
+
+			if testCase.wantFetches > 0 {
+				assert.Equal(t, testCase.wantFetches, fetches.Load())
+			}
+
+			if testCase.wantMetrics == "" {
+				return
+			}
+
+			err = testutil.GatherAndCompare(
+				registry,
+				bytes.NewBufferString(testCase.wantMetrics),
+				"github_actions_workflow_billable_time_seconds",
+			)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestUsageCollector_TriggerRefreshCoalesces(t *testing.T) {
+	var (
+		fetches atomic.Int64
+		started = make(chan struct{})
+		release = make(chan struct{})
+		fetcher = usageFetcherFunc(func(ctx context.Context) (*actions.Usage, error) {
+			// The first refresh is done by the collector itself.
+			if fetches.Add(1) > 1 {
+				close(started)
+				<-release
+			}
+
+			return &stateUsage, nil
+		})
+		collector = actions.NewUsageCollector(
+			fetcher,
+			zaptest.NewLogger(t),
+			10*time.Minute,
+			actions.WithNowFunc(fixedNow(now)),
+			actions.WithSinceFunc(fixedSince(time.Second)),
+		)
+	)
+
+	defer collector.Close()
+
+	<-collector.Ready()
+
+	first := collector.TriggerRefresh("", "")
+	<-started
+	second := collector.TriggerRefresh("", "")
+
+	close(release)
+
+	firstResult, secondResult := <-first, <-second
+
+	require.NoError(t, firstResult.Err)
+	require.NoError(t, secondResult.Err)
+	assert.True(t, secondResult.Coalesced)
+	assert.Equal(t, int64(2), fetches.Load())
+}
+
+func TestUsageCollector_TriggerRefreshRepoWaitsForFullRefresh(t *testing.T) {
+	var (
+		fetches     atomic.Int64
+		started     = make(chan struct{})
+		release     = make(chan struct{})
+		repoFetched = make(chan struct{})
+		fetcher     = repoUsageFetcher{
+			usageFetcherFunc: func(ctx context.Context) (*actions.Usage, error) {
+				// The first refresh is done by the collector itself.
+				if fetches.Add(1) > 1 {
+					close(started)
+					<-release
+				}
+
+				return &stateUsage, nil
+			},
+			fetchRepo: func(_ context.Context, owner, repo string) (*actions.Usage, error) {
+				close(repoFetched)
+
+				return &actions.Usage{
+					Workflows: []actions.WorkflowUsage{
+						{
+							Owner:        owner,
+							Repo:         repo,
+							Workflow:     "build",
+							ID:           1,
+							BillableTime: map[string]time.Duration{"UBUNTU": 30 * time.Second},
+						},
+					},
+				}, nil
+			},
+		}
+		collector = actions.NewUsageCollector(
+			fetcher,
+			zaptest.NewLogger(t),
+			10*time.Minute,
+			actions.WithNowFunc(fixedNow(now)),
+			actions.WithSinceFunc(fixedSince(time.Second)),
+		)
+		registry = prometheus.NewRegistry()
+	)
+
+	defer collector.Close()
+
+	err := registry.Register(collector)
+	require.NoError(t, err)
+
+	<-collector.Ready()
+
+	full := collector.TriggerRefresh("", "")
+	<-started
+	repo := collector.TriggerRefresh("totocorp", "repo-A")
+
+	select {
+	case <-repoFetched:
+		t.Fatal("repo refreshed while a full refresh is in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+
+	fullResult, repoResult := <-full, <-repo
+
+	require.NoError(t, fullResult.Err)
+	require.NoError(t, repoResult.Err)
+
+	// The repo refresh is applied after the full refresh which was in flight.
+	err = testutil.GatherAndCompare(
+		registry,
+		bytes.NewBufferString(`
+# HELP github_actions_workflow_billable_time_seconds Billable time for a repo, per workflow and platform
+# TYPE github_actions_workflow_billable_time_seconds gauge
+github_actions_workflow_billable_time_seconds{owner="totocorp",platform="UBUNTU",repo="repo-A",workflow="build",workflow_id="1"} 30
+`),
+		"github_actions_workflow_billable_time_seconds",
+	)
+	require.NoError(t, err)
+}
+
+func TestUsageCollector_RefreshUsesReplacedFetcher(t *testing.T) {
+	var (
+		fetches    atomic.Int64
+		started    = make(chan struct{})
+		release    = make(chan struct{})
+		oldFetcher = usageFetcherFunc(func(context.Context) (*actions.Usage, error) {
+			// The first refresh is done by the collector itself.
+			if fetches.Add(1) > 1 {
+				close(started)
+				<-release
+			}
+
+			return &stateUsage, nil
+		})
+		newFetcher = usageFetcherFunc(func(context.Context) (*actions.Usage, error) {
+			return &actions.Usage{
+				Workflows: []actions.WorkflowUsage{
+					{
+						Owner:        "totocorp",
+						Repo:         "repo-A",
+						Workflow:     "build",
+						ID:           1,
+						BillableTime: map[string]time.Duration{"UBUNTU": 30 * time.Second},
+					},
+				},
+			}, nil
+		})
+		collector = actions.NewUsageCollector(
+			oldFetcher,
+			zaptest.NewLogger(t),
+			10*time.Minute,
+			actions.WithNowFunc(fixedNow(now)),
+			actions.WithSinceFunc(fixedSince(time.Second)),
+		)
+		registry = prometheus.NewRegistry()
+	)
+
+	defer collector.Close()
+
+	err := registry.Register(collector)
+	require.NoError(t, err)
+
+	<-collector.Ready()
+
+	inFlight := collector.TriggerRefresh("", "")
+	<-started
+
+	collector.SetFetcher(newFetcher)
+	refreshed := collector.Refresh()
+
+	close(release)
+
+	inFlightResult, refreshedResult := <-inFlight, <-refreshed
+
+	require.NoError(t, inFlightResult.Err)
+	require.NoError(t, refreshedResult.Err)
+	assert.False(t, refreshedResult.Coalesced)
+
+	// The refresh happened after the one in flight, with the new fetcher.
+	err = testutil.GatherAndCompare(
+		registry,
+		bytes.NewBufferString(`
+# HELP github_actions_workflow_billable_time_seconds Billable time for a repo, per workflow and platform
+# TYPE github_actions_workflow_billable_time_seconds gauge
+github_actions_workflow_billable_time_seconds{owner="totocorp",platform="UBUNTU",repo="repo-A",workflow="build",workflow_id="1"} 30
+`),
+		"github_actions_workflow_billable_time_seconds",
+	)
+	require.NoError(t, err)
+}
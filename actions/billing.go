@@ -0,0 +1,80 @@
+package actions
+
+import (
+	"context"
+
+	"github.com/google/go-github/v57/github"
+	"go.uber.org/zap"
+)
+
+type BillingFetcher = Fetcher[Billing]
+
+// OrgBilling is what GitHub reports as billed for an organization during the current billing cycle.
+type OrgBilling struct {
+	Owner string
+
+	TotalMinutesUsed     float64
+	TotalPaidMinutesUsed float64
+	IncludedMinutes      float64
+
+	// MinutesUsedBreakdown holds the minutes used per machine type (UBUNTU, WINDOWS, MACOS...).
+	MinutesUsedBreakdown map[string]int
+}
+
+type Billing struct {
+	Orgs []OrgBilling
+
+	// Errors lists the organizations whose billing could not be fetched during this refresh.
+	Errors []FetchError
+}
+
+func (b *Billing) merge(other *Billing) {
+	b.Orgs = append(b.Orgs, other.Orgs...)
+	b.Errors = append(b.Errors, other.Errors...)
+}
+
+func (b *Billing) addError(fetchErr FetchError) {
+	b.Errors = append(b.Errors, fetchErr)
+}
+
+func NewMultiOrgBillingFetcher(fetchers map[string]BillingFetcher, logger *zap.Logger) *MultiOrgFetcher[Billing] {
+	return &MultiOrgFetcher[Billing]{
+		fetchers: fetchers,
+		logger:   logger,
+		merge:    (*Billing).merge,
+		addError: (*Billing).addError,
+	}
+}
+
+// OrgBillingFetcher reads the Actions billing of an organization.
+// It requires the "Administration" organization permission, or the admin:org scope.
+type OrgBillingFetcher struct {
+	gh  *github.Client
+	org string
+}
+
+func NewOrgBillingFetcher(org string, gh *github.Client) *OrgBillingFetcher {
+	return &OrgBillingFetcher{
+		org: org,
+		gh:  gh,
+	}
+}
+
+func (f *OrgBillingFetcher) Fetch(ctx context.Context) (*Billing, error) {
+	billing, _, err := f.gh.Billing.GetActionsBillingOrg(ctx, f.org)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Billing{
+		Orgs: []OrgBilling{
+			{
+				Owner:                f.org,
+				TotalMinutesUsed:     billing.TotalMinutesUsed,
+				TotalPaidMinutesUsed: billing.TotalPaidMinutesUsed,
+				IncludedMinutes:      billing.IncludedMinutes,
+				MinutesUsedBreakdown: billing.MinutesUsedBreakdown,
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,167 @@
+package actions
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+type BillingCollector struct {
+	minutesUsedDesc          *prometheus.Desc
+	paidMinutesUsedDesc      *prometheus.Desc
+	includedMinutesDesc      *prometheus.Desc
+	minutesUsedBreakdownDesc *prometheus.Desc
+
+	loop *refreshLoop
+
+	billingFetcherMu sync.RWMutex
+	billingFetcher   BillingFetcher
+
+	lastBillingDataMu sync.RWMutex
+	lastBillingData   map[string]OrgBilling
+
+	logger *zap.Logger
+}
+
+func NewBillingCollector(billingFetcher BillingFetcher, logger *zap.Logger, refreshPeriod time.Duration) *BillingCollector {
+	c := BillingCollector{
+		logger:          logger,
+		billingFetcher:  billingFetcher,
+		lastBillingData: make(map[string]OrgBilling),
+
+		minutesUsedDesc: prometheus.NewDesc(
+			"github_actions_billing_minutes_used",
+			"Total of Actions minutes used by the organization during the current billing cycle",
+			[]string{"owner"},
+			nil,
+		),
+		paidMinutesUsedDesc: prometheus.NewDesc(
+			"github_actions_billing_paid_minutes_used",
+			"Total of paid Actions minutes used by the organization during the current billing cycle",
+			[]string{"owner"},
+			nil,
+		),
+		includedMinutesDesc: prometheus.NewDesc(
+			"github_actions_billing_included_minutes",
+			"Actions minutes included in the plan of the organization",
+			[]string{"owner"},
+			nil,
+		),
+		minutesUsedBreakdownDesc: prometheus.NewDesc(
+			"github_actions_billing_minutes_used_breakdown",
+			"Actions minutes used by the organization during the current billing cycle, per machine type",
+			[]string{"owner", "platform"},
+			nil,
+		),
+	}
+
+	c.loop = startRefreshLoop(refreshPeriod, c.refresh)
+
+	return &c
+}
+
+func (c *BillingCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.minutesUsedDesc
+	ch <- c.paidMinutesUsedDesc
+	ch <- c.includedMinutesDesc
+	ch <- c.minutesUsedBreakdownDesc
+}
+
+func (c *BillingCollector) Collect(ch chan<- prometheus.Metric) {
+	c.lastBillingDataMu.RLock()
+	defer c.lastBillingDataMu.RUnlock()
+
+	for owner, billing := range c.lastBillingData {
+		ch <- prometheus.MustNewConstMetric(
+			c.minutesUsedDesc,
+			prometheus.GaugeValue,
+			billing.TotalMinutesUsed,
+			owner,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.paidMinutesUsedDesc,
+			prometheus.GaugeValue,
+			billing.TotalPaidMinutesUsed,
+			owner,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.includedMinutesDesc,
+			prometheus.GaugeValue,
+			billing.IncludedMinutes,
+			owner,
+		)
+
+		for platform, minutes := range billing.MinutesUsedBreakdown {
+			ch <- prometheus.MustNewConstMetric(
+				c.minutesUsedBreakdownDesc,
+				prometheus.GaugeValue,
+				float64(minutes),
+				owner,
+				platform,
+			)
+		}
+	}
+}
+
+func (c *BillingCollector) Close() error {
+	return c.loop.Close()
+}
+
+func (c *BillingCollector) Ready() <-chan struct{} {
+	return c.loop.Ready()
+}
+
+// SetFetcher replaces the billing fetcher, for instance when the configuration changes. It is used from the next refresh on.
+func (c *BillingCollector) SetFetcher(fetcher BillingFetcher) {
+	c.billingFetcherMu.Lock()
+	defer c.billingFetcherMu.Unlock()
+
+	c.billingFetcher = fetcher
+}
+
+func (c *BillingCollector) fetcher() BillingFetcher {
+	c.billingFetcherMu.RLock()
+	defer c.billingFetcherMu.RUnlock()
+
+	return c.billingFetcher
+}
+
+func (c *BillingCollector) refresh(ctx context.Context) {
+	c.logger.Info("Refreshing billing data")
+
+	billingData, err := c.fetcher().Fetch(ctx)
+	if err != nil {
+		c.logger.Error(
+			"Could not retrieve updated billing data",
+			zap.Error(err),
+		)
+
+		return
+	}
+
+	c.logger.Info(
+		"Done refreshing billing data",
+		zap.Int("organizations", len(billingData.Orgs)),
+		zap.Int("errors", len(billingData.Errors)),
+	)
+
+	lastBillingData := make(map[string]OrgBilling, len(billingData.Orgs))
+	for _, billing := range billingData.Orgs {
+		lastBillingData[billing.Owner] = billing
+	}
+
+	c.lastBillingDataMu.Lock()
+	// Keep serving the last known billing of the organizations that failed this time,
+	// it changes slowly and is better than a gap right before the quota runs out.
+	// Organizations which are not monitored anymore are dropped.
+	for _, fetchErr := range billingData.Errors {
+		if billing, ok := c.lastBillingData[fetchErr.Owner]; ok {
+			lastBillingData[fetchErr.Owner] = billing
+		}
+	}
+	c.lastBillingData = lastBillingData
+	c.lastBillingDataMu.Unlock()
+}
@@ -0,0 +1,141 @@
+package actions_test
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+	"github.com/jlevesy/workflows-exporter/actions"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestBillingCollector(t *testing.T) {
+	var (
+		logger = zaptest.NewLogger(t)
+		gh     = github.NewClient(
+			mock.NewMockedHTTPClient(
+				mock.WithRequestMatchHandler(
+					mock.GetOrgsSettingsBillingActionsByOrg,
+					failingHandler(
+						"/orgs/tatacorp/",
+						paginatedHandler(github.ActionBilling{
+							TotalMinutesUsed:     3305,
+							TotalPaidMinutesUsed: 305,
+							IncludedMinutes:      3000,
+							MinutesUsedBreakdown: github.MinutesUsedBreakdown{
+								"UBUNTU":  2205,
+								"MACOS":   100,
+								"WINDOWS": 1000,
+							},
+						}),
+					),
+				),
+			),
+		)
+		fetcher = actions.NewMultiOrgBillingFetcher(
+			map[string]actions.BillingFetcher{
+				"totocorp": actions.NewOrgBillingFetcher("totocorp", gh),
+				"tatacorp": actions.NewOrgBillingFetcher("tatacorp", gh),
+			},
+			logger,
+		)
+		collector = actions.NewBillingCollector(fetcher, logger, 10*time.Minute)
+		registry  = prometheus.NewRegistry()
+	)
+
+	defer collector.Close()
+
+	err := registry.Register(collector)
+	require.NoError(t, err)
+
+	<-collector.Ready()
+
+	err = testutil.GatherAndCompare(
+		registry,
+		bytes.NewBufferString(`
+# HELP github_actions_billing_included_minutes Actions minutes included in the plan of the organization
+# TYPE github_actions_billing_included_minutes gauge
+github_actions_billing_included_minutes{owner="totocorp"} 3000
+# HELP github_actions_billing_minutes_used Total of Actions minutes used by the organization during the current billing cycle
+# TYPE github_actions_billing_minutes_used gauge
+github_actions_billing_minutes_used{owner="totocorp"} 3305
+# HELP github_actions_billing_minutes_used_breakdown Actions minutes used by the organization during the current billing cycle, per machine type
+# TYPE github_actions_billing_minutes_used_breakdown gauge
+github_actions_billing_minutes_used_breakdown{owner="totocorp",platform="MACOS"} 100
+github_actions_billing_minutes_used_breakdown{owner="totocorp",platform="UBUNTU"} 2205
+github_actions_billing_minutes_used_breakdown{owner="totocorp",platform="WINDOWS"} 1000
+# HELP github_actions_billing_paid_minutes_used Total of paid Actions minutes used by the organization during the current billing cycle
+# TYPE github_actions_billing_paid_minutes_used gauge
+github_actions_billing_paid_minutes_used{owner="totocorp"} 305
+`),
+	)
+	require.NoError(t, err)
+}
+
+func TestBillingCollector_Reload(t *testing.T) {
+	var (
+		// The refresh loop keeps ticking fast after the test completes, which a test logger does not tolerate.
+		logger  = zap.NewNop()
+		failing atomic.Bool
+		gh      = github.NewClient(
+			mock.NewMockedHTTPClient(
+				mock.WithRequestMatchHandler(
+					mock.GetOrgsSettingsBillingActionsByOrg,
+					http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						if failing.Load() && strings.HasPrefix(r.URL.Path, "/orgs/totocorp/") {
+							w.WriteHeader(http.StatusInternalServerError)
+							return
+						}
+
+						_, _ = w.Write(mock.MustMarshal(github.ActionBilling{
+							TotalMinutesUsed: 3305,
+						}))
+					}),
+				),
+			),
+		)
+		newFetcher = func(orgs ...string) *actions.MultiOrgFetcher[actions.Billing] {
+			fetchers := make(map[string]actions.BillingFetcher, len(orgs))
+			for _, org := range orgs {
+				fetchers[org] = actions.NewOrgBillingFetcher(org, gh)
+			}
+
+			return actions.NewMultiOrgBillingFetcher(fetchers, logger)
+		}
+		collector = actions.NewBillingCollector(newFetcher("totocorp", "tatacorp"), logger, 10*time.Millisecond)
+		registry  = prometheus.NewRegistry()
+	)
+
+	defer collector.Close()
+
+	err := registry.Register(collector)
+	require.NoError(t, err)
+
+	<-collector.Ready()
+
+	// tatacorp is not monitored anymore and totocorp fails: its last known billing is still served.
+	failing.Store(true)
+	collector.SetFetcher(newFetcher("totocorp", "titicorp"))
+
+	require.Eventually(t, func() bool {
+		return testutil.GatherAndCompare(
+			registry,
+			bytes.NewBufferString(`
+# HELP github_actions_billing_minutes_used Total of Actions minutes used by the organization during the current billing cycle
+# TYPE github_actions_billing_minutes_used gauge
+github_actions_billing_minutes_used{owner="titicorp"} 3305
+github_actions_billing_minutes_used{owner="totocorp"} 3305
+`),
+			"github_actions_billing_minutes_used",
+		) == nil
+	}, 2*time.Second, 10*time.Millisecond)
+}
@@ -2,12 +2,16 @@ package actions
 
 import (
 	"context"
+	"errors"
+	"io/fs"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 )
 
 type UsageCollectorOpt func(c *UsageCollector)
@@ -26,23 +30,88 @@ func WithSinceFunc(fn SinceFunc) UsageCollectorOpt {
 	}
 }
 
+// WithPriceTable sets the prices used to estimate the cost of the billable time.
+func WithPriceTable(table PriceTable) UsageCollectorOpt {
+	return func(c *UsageCollector) {
+		c.priceTable = table
+	}
+}
+
+// WithStateFile persists the last usage data to path after each successful refresh, and restores it on startup.
+// If the restored data is younger than maxAge, the collector is ready right away and the next
+// refresh is scheduled a refresh period after the restored one. A zero maxAge defaults to the refresh period.
+func WithStateFile(path string, maxAge time.Duration) UsageCollectorOpt {
+	return func(c *UsageCollector) {
+		c.stateFile = path
+		c.stateMaxAge = maxAge
+	}
+}
+
+// WithStaleThreshold sets how old the last successful refresh can be before the data is reported as stale.
+func WithStaleThreshold(threshold time.Duration) UsageCollectorOpt {
+	return func(c *UsageCollector) {
+		c.staleThreshold = threshold
+	}
+}
+
+// WithStaggeredRefresh refreshes the usage of each repository on its own schedule, spread across the refresh period,
+// instead of refreshing everything at once. Hot repositories are refreshed more often than cold ones.
+// The usage fetcher must be able to list repositories and to fetch a single one, otherwise it is ignored.
+func WithStaggeredRefresh(config StaggeredRefreshConfig) UsageCollectorOpt {
+	return func(c *UsageCollector) {
+		c.staggeredRefresh = &config
+	}
+}
+
+const defaultStaleRefreshPeriods = 3
+
 type UsageCollector struct {
 	billableTimeDesc        *prometheus.Desc
+	estimatedCostDesc       *prometheus.Desc
 	lastRefreshTimeDesc     *prometheus.Desc
 	lastRefreshDurationDesc *prometheus.Desc
 	activeReposDesc         *prometheus.Desc
+	fetchErrorsDesc         *prometheus.Desc
+	filteredReposDesc       *prometheus.Desc
+	refreshAttemptsDesc     *prometheus.Desc
+	refreshSuccessesDesc    *prometheus.Desc
+	refreshFailuresDesc     *prometheus.Desc
+	lastFailureTimeDesc     *prometheus.Desc
+	dataStaleDesc           *prometheus.Desc
 
-	refreshTicker *time.Ticker
-	cancelFunc    func()
+	loop             refresher
+	refreshGroup     singleflight.Group
+	staggeredRefresh *StaggeredRefreshConfig
 
-	usagefetcher WorkflowUsageFetcher
+	// refreshMu serializes the full refreshes with the repo refreshes, so that a full refresh which started earlier
+	// does not overwrite the more recent usage of a repo. Repo refreshes can run concurrently.
+	refreshMu sync.RWMutex
 
-	ready chan struct{}
+	usagefetcherMu sync.RWMutex
+	usagefetcher   WorkflowUsageFetcher
 
 	lastUsageDataMu     sync.RWMutex
 	lastUsageData       *Usage
 	lastRefreshTime     time.Time
 	lastRefreshDuration time.Duration
+	fetchErrors         map[fetchErrorKey]float64
+
+	refreshAttempts  float64
+	refreshSuccesses float64
+	refreshFailures  map[string]float64
+	lastFailureTime  time.Time
+	staleThreshold   time.Duration
+
+	priceTable PriceTable
+
+	stateFile   string
+	stateMaxAge time.Duration
+
+	startTime time.Time
+	// refreshed is set once a refresh succeeded since the collector creation, restored data does not count.
+	refreshed bool
+	// restoredFresh is set when the restored data is young enough to be served without being refreshed first.
+	restoredFresh bool
 
 	logger    *zap.Logger
 	nowFunc   func() time.Time
@@ -50,16 +119,16 @@ type UsageCollector struct {
 }
 
 func NewUsageCollector(usagefetcher WorkflowUsageFetcher, logger *zap.Logger, refreshPeriod time.Duration, opts ...UsageCollectorOpt) *UsageCollector {
-	ctx, cancel := context.WithCancel(context.Background())
-
 	c := UsageCollector{
-		cancelFunc:    cancel,
-		refreshTicker: time.NewTicker(refreshPeriod),
-		logger:        logger,
-		ready:         make(chan struct{}),
-		usagefetcher:  usagefetcher,
-		nowFunc:       time.Now,
-		sinceFunc:     since,
+		logger:       logger,
+		usagefetcher: usagefetcher,
+		nowFunc:      time.Now,
+		sinceFunc:    since,
+		priceTable:   DefaultPriceTable,
+		fetchErrors:  make(map[fetchErrorKey]float64),
+
+		refreshFailures: make(map[string]float64),
+		staleThreshold:  defaultStaleRefreshPeriods * refreshPeriod,
 
 		billableTimeDesc: prometheus.NewDesc(
 			"github_actions_workflow_billable_time_seconds",
@@ -67,6 +136,12 @@ func NewUsageCollector(usagefetcher WorkflowUsageFetcher, logger *zap.Logger, re
 			[]string{"owner", "repo", "workflow", "workflow_id", "platform"},
 			nil,
 		),
+		estimatedCostDesc: prometheus.NewDesc(
+			"github_actions_workflow_estimated_cost_dollars",
+			"Estimated cost in dollars of the billable time for a repo, per workflow and platform",
+			[]string{"owner", "repo", "workflow", "workflow_id", "platform"},
+			nil,
+		),
 		lastRefreshTimeDesc: prometheus.NewDesc(
 			"github_actions_workflow_last_refresh_timestamp_seconds",
 			"Last timestamp in seconds since epoch of the last dataset refresh",
@@ -85,34 +160,104 @@ func NewUsageCollector(usagefetcher WorkflowUsageFetcher, logger *zap.Logger, re
 			nil,
 			nil,
 		),
+		filteredReposDesc: prometheus.NewDesc(
+			"github_actions_workflow_filtered_repos",
+			"Last reported total of active repositories ignored by the repository filters, per reason",
+			[]string{"owner", "reason"},
+			nil,
+		),
+		fetchErrorsDesc: prometheus.NewDesc(
+			"github_actions_workflow_fetch_errors_total",
+			"Total of errors encountered while fetching usage data, per repo and stage",
+			[]string{"owner", "repo", "stage"},
+			nil,
+		),
+		refreshAttemptsDesc: prometheus.NewDesc(
+			"github_actions_workflow_refresh_attempts_total",
+			"Total of usage data refreshes attempted",
+			nil,
+			nil,
+		),
+		refreshSuccessesDesc: prometheus.NewDesc(
+			"github_actions_workflow_refresh_successes_total",
+			"Total of usage data refreshes that succeeded, possibly with partial data",
+			nil,
+			nil,
+		),
+		refreshFailuresDesc: prometheus.NewDesc(
+			"github_actions_workflow_refresh_failures_total",
+			"Total of usage data refreshes that failed, per error class",
+			[]string{"class"},
+			nil,
+		),
+		lastFailureTimeDesc: prometheus.NewDesc(
+			"github_actions_workflow_last_refresh_failure_timestamp_seconds",
+			"Timestamp in seconds since epoch of the last failed usage data refresh",
+			nil,
+			nil,
+		),
+		dataStaleDesc: prometheus.NewDesc(
+			"github_actions_workflow_data_stale",
+			"Whether the last successful refresh is older than the staleness threshold",
+			nil,
+			nil,
+		),
 	}
 
 	for _, opt := range opts {
 		opt(&c)
 	}
 
-	go func() {
-		c.refresh(ctx)
+	c.startTime = c.nowFunc()
 
-		close(c.ready)
+	var (
+		delay    time.Duration
+		restored bool
+	)
 
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-c.refreshTicker.C:
-				c.refresh(ctx)
-			}
+	if c.stateFile != "" {
+		delay, restored = c.restoreState(refreshPeriod)
+		c.restoredFresh = restored
+	}
+
+	if c.staggeredRefresh != nil {
+		_, canList := usagefetcher.(RepoLister)
+		_, canFetchRepo := usagefetcher.(RepoUsageFetcher)
+
+		if canList && canFetchRepo {
+			c.loop = startRepoScheduler(&c, refreshPeriod, *c.staggeredRefresh, restored)
+
+			return &c
 		}
-	}()
+
+		c.logger.Warn("Usage fetcher does not support staggered refreshes, refreshing everything at once")
+		c.staggeredRefresh = nil
+	}
+
+	if restored {
+		c.loop = startDelayedRefreshLoop(delay, refreshPeriod, c.refresh)
+
+		return &c
+	}
+
+	c.loop = startRefreshLoop(refreshPeriod, c.refresh)
 
 	return &c
 }
+
 func (c *UsageCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.billableTimeDesc
+	ch <- c.estimatedCostDesc
 	ch <- c.lastRefreshTimeDesc
 	ch <- c.lastRefreshDurationDesc
 	ch <- c.activeReposDesc
+	ch <- c.filteredReposDesc
+	ch <- c.fetchErrorsDesc
+	ch <- c.refreshAttemptsDesc
+	ch <- c.refreshSuccessesDesc
+	ch <- c.refreshFailuresDesc
+	ch <- c.lastFailureTimeDesc
+	ch <- c.dataStaleDesc
 }
 
 func (c *UsageCollector) Collect(ch chan<- prometheus.Metric) {
@@ -132,6 +277,22 @@ func (c *UsageCollector) Collect(ch chan<- prometheus.Metric) {
 					strconv.FormatInt(workflowData.ID, 10),
 					platform,
 				)
+
+				cost, ok := c.priceTable.EstimateCost(platform, value)
+				if !ok {
+					continue
+				}
+
+				ch <- prometheus.MustNewConstMetric(
+					c.estimatedCostDesc,
+					prometheus.GaugeValue,
+					cost,
+					workflowData.Owner,
+					workflowData.Repo,
+					workflowData.Workflow,
+					strconv.FormatInt(workflowData.ID, 10),
+					platform,
+				)
 			}
 		}
 
@@ -140,8 +301,69 @@ func (c *UsageCollector) Collect(ch chan<- prometheus.Metric) {
 			prometheus.GaugeValue,
 			float64(c.lastUsageData.ActiveRepos),
 		)
+
+		for _, filtered := range c.lastUsageData.FilteredRepos {
+			ch <- prometheus.MustNewConstMetric(
+				c.filteredReposDesc,
+				prometheus.GaugeValue,
+				float64(filtered.Count),
+				filtered.Owner,
+				filtered.Reason,
+			)
+		}
+	}
+
+	for key, value := range c.fetchErrors {
+		ch <- prometheus.MustNewConstMetric(
+			c.fetchErrorsDesc,
+			prometheus.CounterValue,
+			value,
+			key.owner,
+			key.repo,
+			key.stage,
+		)
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.refreshAttemptsDesc,
+		prometheus.CounterValue,
+		c.refreshAttempts,
+	)
+
+	ch <- prometheus.MustNewConstMetric(
+		c.refreshSuccessesDesc,
+		prometheus.CounterValue,
+		c.refreshSuccesses,
+	)
+
+	for class, value := range c.refreshFailures {
+		ch <- prometheus.MustNewConstMetric(
+			c.refreshFailuresDesc,
+			prometheus.CounterValue,
+			value,
+			class,
+		)
 	}
 
+	if !c.lastFailureTime.IsZero() {
+		ch <- prometheus.MustNewConstMetric(
+			c.lastFailureTimeDesc,
+			prometheus.GaugeValue,
+			float64(c.lastFailureTime.Unix()),
+		)
+	}
+
+	var stale float64
+	if c.dataAgeLocked() > c.staleThreshold {
+		stale = 1
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.dataStaleDesc,
+		prometheus.GaugeValue,
+		stale,
+	)
+
 	if !c.lastRefreshTime.IsZero() {
 		ch <- prometheus.MustNewConstMetric(
 			c.lastRefreshTimeDesc,
@@ -160,40 +382,423 @@ func (c *UsageCollector) Collect(ch chan<- prometheus.Metric) {
 }
 
 func (c *UsageCollector) Close() error {
-	c.cancelFunc()
-	c.refreshTicker.Stop()
-
-	return nil
+	return c.loop.Close()
 }
 
 func (c *UsageCollector) Ready() <-chan struct{} {
-	return c.ready
+	return c.loop.Ready()
 }
 
+// RefreshResult is the outcome of a refresh triggered by TriggerRefresh.
+type RefreshResult struct {
+	Err error
+	// Coalesced is set when the refresh was already in flight, and its outcome is shared with other callers.
+	Coalesced bool
+}
+
+// TriggerRefresh refreshes the whole dataset, or only the workflows of a single repository if repo is not empty.
+// If the same refresh is already in flight, no new one is started and the outcome of the ongoing one is reported.
+func (c *UsageCollector) TriggerRefresh(owner, repo string) <-chan RefreshResult {
+	return c.triggerRefresh(c.loop.Context(), owner, repo)
+}
+
+func (c *UsageCollector) triggerRefresh(ctx context.Context, owner, repo string) <-chan RefreshResult {
+	var (
+		key    = fullRefreshKey
+		result = make(chan RefreshResult, 1)
+		fn     = func() (any, error) { return nil, c.refreshAll(ctx) }
+	)
+
+	if repo != "" {
+		key = repoKey(owner, repo)
+		fn = func() (any, error) { return nil, c.refreshRepo(ctx, owner, repo) }
+	}
+
+	ch := c.refreshGroup.DoChan(key, fn)
+
+	go func() {
+		res := <-ch
+		result <- RefreshResult{Err: res.Err, Coalesced: res.Shared}
+	}()
+
+	return result
+}
+
+const fullRefreshKey = ""
+
+// Refresh refreshes the whole dataset with the current usage fetcher, once the full refresh in flight, if any, is done.
+// Unlike TriggerRefresh, it never shares the outcome of a refresh in flight, which could be using a fetcher replaced
+// since by SetFetcher.
+func (c *UsageCollector) Refresh() <-chan RefreshResult {
+	var (
+		ctx    = c.loop.Context()
+		result = make(chan RefreshResult, 1)
+	)
+
+	go func() {
+		result <- RefreshResult{Err: c.refreshAll(ctx)}
+	}()
+
+	return result
+}
+
+// refresh is called by the refresh loop, it goes through the same path as triggered refreshes so that they can be coalesced.
 func (c *UsageCollector) refresh(ctx context.Context) {
+	<-c.refreshGroup.DoChan(fullRefreshKey, func() (any, error) {
+		return nil, c.refreshAll(ctx)
+	})
+}
+
+func (c *UsageCollector) refreshAll(ctx context.Context) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
 	c.logger.Info("Refreshing usage data")
 
 	startTime := c.nowFunc()
-	usageData, err := c.usagefetcher.Fetch(ctx)
+	usageData, err := c.fetcher().Fetch(ctx)
+	endTime := c.nowFunc()
 	if err != nil {
+		class := ClassifyError(err)
+
 		c.logger.Error(
 			"Could not retrieve updated usage data",
+			zap.String("class", class),
 			zap.Error(err),
 		)
 
-		return
+		c.lastUsageDataMu.Lock()
+		c.refreshAttempts++
+		c.refreshFailures[class]++
+		c.lastFailureTime = endTime
+		c.lastUsageDataMu.Unlock()
+
+		return err
 	}
-	endTime := c.nowFunc()
 
 	duration := c.sinceFunc(startTime, endTime)
 
-	c.logger.Info("Done refreshing usage data", zap.Duration("took", duration))
+	c.logger.Info(
+		"Done refreshing usage data",
+		zap.Duration("took", duration),
+		zap.Int("errors", len(usageData.Errors)),
+	)
 
 	c.lastUsageDataMu.Lock()
+	c.refreshAttempts++
+	c.refreshSuccesses++
 	c.lastUsageData = usageData
 	c.lastRefreshDuration = duration
 	c.lastRefreshTime = endTime
+	c.refreshed = true
+	for _, fetchErr := range usageData.Errors {
+		c.fetchErrors[fetchErrorKey{
+			owner: fetchErr.Owner,
+			repo:  fetchErr.Repo,
+			stage: fetchErr.Stage,
+		}]++
+	}
 	c.lastUsageDataMu.Unlock()
+
+	c.saveState(usageSnapshot{
+		RefreshTime:     endTime,
+		RefreshDuration: duration,
+		Usage:           usageData,
+	})
+
+	return nil
+}
+
+var errNoUsageData = errors.New("no usage data available yet")
+
+// refreshRepo only refreshes the workflows of a single repository, and updates them in the last usage data.
+// With staggered refreshes, the dataset is refreshed one repo at a time: the last refresh time reports the last
+// successful repo refresh. Otherwise it is left untouched as it reports the age of the whole dataset.
+// It waits for the full refresh in flight, if any.
+func (c *UsageCollector) refreshRepo(ctx context.Context, owner, repo string) error {
+	c.refreshMu.RLock()
+	defer c.refreshMu.RUnlock()
+
+	fetcher, ok := c.fetcher().(RepoUsageFetcher)
+	if !ok {
+		return errors.New("usage fetcher does not support refreshing a single repo")
+	}
+
+	c.lastUsageDataMu.RLock()
+	hasData := c.lastUsageData != nil
+	c.lastUsageDataMu.RUnlock()
+
+	// Nothing to update yet, the first full refresh failed.
+	if !hasData {
+		return errNoUsageData
+	}
+
+	c.logger.Info("Refreshing usage data for repo", zap.String("owner", owner), zap.String("repo", repo))
+
+	startTime := c.nowFunc()
+	repoUsage, err := fetcher.FetchRepo(ctx, owner, repo)
+	endTime := c.nowFunc()
+	if err != nil {
+		// The repository is not monitored, which is not a failure to refresh it.
+		if errors.Is(err, ErrUnknownOwner) || errors.Is(err, ErrRepoNotCollected) {
+			return err
+		}
+
+		class := ClassifyError(err)
+
+		c.logger.Error(
+			"Could not retrieve updated usage data for repo",
+			zap.String("owner", owner),
+			zap.String("repo", repo),
+			zap.String("class", class),
+			zap.Error(err),
+		)
+
+		c.lastUsageDataMu.Lock()
+		c.refreshAttempts++
+		c.refreshFailures[class]++
+		c.lastFailureTime = endTime
+		c.fetchErrors[fetchErrorKey{
+			owner: owner,
+			repo:  repo,
+			stage: StageFetchRepo,
+		}]++
+		c.lastUsageDataMu.Unlock()
+
+		return err
+	}
+
+	c.lastUsageDataMu.Lock()
+	c.refreshAttempts++
+	c.refreshSuccesses++
+	c.lastUsageData = c.lastUsageData.withRepo(owner, repo, repoUsage)
+	if c.staggeredRefresh != nil {
+		c.lastRefreshDuration = c.sinceFunc(startTime, endTime)
+		c.lastRefreshTime = endTime
+		c.refreshed = true
+	}
+	for _, fetchErr := range repoUsage.Errors {
+		c.fetchErrors[fetchErrorKey{
+			owner: fetchErr.Owner,
+			repo:  fetchErr.Repo,
+			stage: fetchErr.Stage,
+		}]++
+	}
+	snapshot := usageSnapshot{
+		RefreshTime:     c.lastRefreshTime,
+		RefreshDuration: c.lastRefreshDuration,
+		Usage:           c.lastUsageData,
+	}
+	c.lastUsageDataMu.Unlock()
+
+	c.saveState(snapshot)
+
+	return nil
+}
+
+// SetFetcher replaces the usage fetcher, for instance when the configuration changes. The data is kept
+// until the next refresh done with the new fetcher, which is not triggered by this call, see Refresh.
+func (c *UsageCollector) SetFetcher(fetcher WorkflowUsageFetcher) {
+	c.usagefetcherMu.Lock()
+	defer c.usagefetcherMu.Unlock()
+
+	c.usagefetcher = fetcher
+}
+
+func (c *UsageCollector) fetcher() WorkflowUsageFetcher {
+	c.usagefetcherMu.RLock()
+	defer c.usagefetcherMu.RUnlock()
+
+	return c.usagefetcher
+}
+
+// SetPriceTable replaces the prices used to estimate the cost of the billable time.
+func (c *UsageCollector) SetPriceTable(table PriceTable) {
+	c.lastUsageDataMu.Lock()
+	defer c.lastUsageDataMu.Unlock()
+
+	c.priceTable = table
+}
+
+// dataAge tells how long ago the usage data has been refreshed, or restored from, and if there is any.
+func (c *UsageCollector) dataAge() (time.Duration, bool) {
+	c.lastUsageDataMu.RLock()
+	defer c.lastUsageDataMu.RUnlock()
+
+	if c.lastUsageData == nil {
+		return 0, false
+	}
+
+	return c.nowFunc().Sub(c.lastRefreshTime), true
+}
+
+func (c *UsageCollector) hasUsageData() bool {
+	c.lastUsageDataMu.RLock()
+	defer c.lastUsageDataMu.RUnlock()
+
+	return c.lastUsageData != nil
+}
+
+// applyRepoList records the outcome of a listing of the active repositories by the scheduler, and drops the usage of
+// the repositories which are not active anymore. A failed listing counts as a failed refresh, but a successful one
+// does not refresh any usage: the refresh metrics and the last refresh time are driven by the repo refreshes.
+func (c *UsageCollector) applyRepoList(list *RepoList, err error, endTime time.Time) {
+	if err != nil {
+		class := ClassifyError(err)
+
+		c.logger.Error(
+			"Could not list active repositories",
+			zap.String("class", class),
+			zap.Error(err),
+		)
+
+		c.lastUsageDataMu.Lock()
+		c.refreshAttempts++
+		c.refreshFailures[class]++
+		c.lastFailureTime = endTime
+		c.lastUsageDataMu.Unlock()
+
+		return
+	}
+
+	var (
+		listed       = make(map[string]struct{}, len(list.Repos))
+		failedOwners = make(map[string]struct{})
+	)
+
+	for _, repo := range list.Repos {
+		listed[repoKey(repo.Owner, repo.Name)] = struct{}{}
+	}
+
+	for _, fetchErr := range list.Errors {
+		failedOwners[strings.ToLower(fetchErr.Owner)] = struct{}{}
+	}
+
+	c.lastUsageDataMu.Lock()
+
+	// Nothing to update without a first full refresh, a listing alone is no usage data.
+	if c.lastUsageData == nil {
+		c.lastUsageDataMu.Unlock()
+		return
+	}
+
+	// Errors of the previous listing are dropped along the way, as they are not attached to a repo.
+	usage := c.lastUsageData.filterRepos(func(owner, repo string) bool {
+		if _, ok := failedOwners[strings.ToLower(owner)]; ok {
+			return repo != ""
+		}
+
+		_, ok := listed[repoKey(owner, repo)]
+		return ok
+	})
+
+	usage.ActiveRepos = list.ActiveRepos
+	usage.FilteredRepos = list.FilteredRepos
+	usage.Errors = append(usage.Errors, list.Errors...)
+
+	c.lastUsageData = usage
+	for _, fetchErr := range list.Errors {
+		c.fetchErrors[fetchErrorKey{
+			owner: fetchErr.Owner,
+			repo:  fetchErr.Repo,
+			stage: fetchErr.Stage,
+		}]++
+	}
+	snapshot := usageSnapshot{
+		RefreshTime:     c.lastRefreshTime,
+		RefreshDuration: c.lastRefreshDuration,
+		Usage:           usage,
+	}
+	c.lastUsageDataMu.Unlock()
+
+	c.saveState(snapshot)
+}
+
+// dropRepo removes the usage of a repository which is not collected anymore.
+func (c *UsageCollector) dropRepo(owner, repo string) {
+	c.lastUsageDataMu.Lock()
+	defer c.lastUsageDataMu.Unlock()
+
+	if c.lastUsageData == nil {
+		return
+	}
+
+	c.lastUsageData = c.lastUsageData.withRepo(owner, repo, &Usage{})
+}
+
+func (c *UsageCollector) saveState(snapshot usageSnapshot) {
+	if c.stateFile == "" {
+		return
+	}
+
+	if err := saveUsageSnapshot(c.stateFile, snapshot); err != nil {
+		c.logger.Error("Could not save usage data to the state file", zap.String("path", c.stateFile), zap.Error(err))
+	}
+}
+
+// restoreState loads the state file, and tells when the next refresh is due if the restored data is fresh enough.
+// Stale data is still served until the first refresh completes.
+func (c *UsageCollector) restoreState(refreshPeriod time.Duration) (time.Duration, bool) {
+	snapshot, err := loadUsageSnapshot(c.stateFile)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		c.logger.Info("No state file to restore usage data from", zap.String("path", c.stateFile))
+		return 0, false
+	case err != nil:
+		c.logger.Warn("Could not restore usage data from the state file", zap.String("path", c.stateFile), zap.Error(err))
+		return 0, false
+	}
+
+	c.lastUsageData = snapshot.Usage
+	c.lastRefreshTime = snapshot.RefreshTime
+	c.lastRefreshDuration = snapshot.RefreshDuration
+
+	maxAge := c.stateMaxAge
+	if maxAge == 0 {
+		maxAge = refreshPeriod
+	}
+
+	age := c.nowFunc().Sub(snapshot.RefreshTime)
+
+	c.logger.Info(
+		"Restored usage data from the state file",
+		zap.String("path", c.stateFile),
+		zap.Time("refresh_time", snapshot.RefreshTime),
+		zap.Duration("age", age),
+	)
+
+	if age >= maxAge {
+		return 0, false
+	}
+
+	return max(refreshPeriod-age, 0), true
+}
+
+// dataAgeLocked tells how old the served data is. Until data is available, the age is counted from the collector creation.
+// lastUsageDataMu must be held.
+func (c *UsageCollector) dataAgeLocked() time.Duration {
+	if c.lastUsageData == nil {
+		return c.nowFunc().Sub(c.startTime)
+	}
+
+	return c.nowFunc().Sub(c.lastRefreshTime)
+}
+
+// healthAgeLocked tells how old the served data is for the health check. Until the first refresh completes, the age is
+// counted from the collector creation, so that restoring stale data does not fail the health check before the
+// exporter had a chance to refresh it. lastUsageDataMu must be held.
+func (c *UsageCollector) healthAgeLocked() time.Duration {
+	if !c.refreshed {
+		return c.nowFunc().Sub(c.startTime)
+	}
+
+	return c.nowFunc().Sub(c.lastRefreshTime)
+}
+
+type fetchErrorKey struct {
+	owner string
+	repo  string
+	stage string
 }
 
 func since(t1, t2 time.Time) time.Duration { return t2.Sub(t1) }
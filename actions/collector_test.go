@@ -2,6 +2,9 @@ package actions_test
 
 import (
 	"bytes"
+	"net/http"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -107,14 +110,72 @@ var (
 			workflowTiming,
 		),
 	}
+
+	// repo-C can't list its workflows, and the timing of workflow 2 always fails.
+	partialFailureMockBehavior = []mock.MockBackendOption{
+		mock.WithRequestMatchPages(mock.GetOrgsReposByOrg, repos...),
+		mock.WithRequestMatchHandler(
+			mock.GetReposActionsWorkflowsByOwnerByRepo,
+			failingHandler(
+				"/repos/totocorp/repo-C/",
+				paginatedHandler(workflows...),
+			),
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetReposActionsWorkflowsTimingByOwnerByRepoByWorkflowId,
+			failingHandler(
+				"/workflows/2/",
+				paginatedHandler(workflowTiming),
+			),
+		),
+	}
 )
 
 func TestCollector(t *testing.T) {
 	for _, testCase := range []struct {
-		metricName  string
-		mockOptions []mock.MockBackendOption
-		wantMetrics string
+		metricName    string
+		mockOptions   []mock.MockBackendOption
+		fetcherOpts   []actions.FetcherOpt
+		collectorOpts []actions.UsageCollectorOpt
+		wantMetrics   string
 	}{
+		{
+			metricName:  "github_actions_workflow_estimated_cost_dollars",
+			mockOptions: defaultMockBehavior,
+			fetcherOpts: []actions.FetcherOpt{
+				actions.WithRepoFilter(actions.RepoFilter{
+					Include: []*regexp.Regexp{regexp.MustCompile("^repo-A$")},
+				}),
+			},
+			wantMetrics: `
+# HELP github_actions_workflow_estimated_cost_dollars Estimated cost in dollars of the billable time for a repo, per workflow and platform
+# TYPE github_actions_workflow_estimated_cost_dollars gauge
+github_actions_workflow_estimated_cost_dollars{owner="totocorp",platform="UBUNTU",repo="repo-A",workflow="build",workflow_id="1"} 0.008
+github_actions_workflow_estimated_cost_dollars{owner="totocorp",platform="UBUNTU",repo="repo-A",workflow="release",workflow_id="3"} 0.008
+github_actions_workflow_estimated_cost_dollars{owner="totocorp",platform="UBUNTU",repo="repo-A",workflow="run",workflow_id="4"} 0.008
+github_actions_workflow_estimated_cost_dollars{owner="totocorp",platform="UBUNTU",repo="repo-A",workflow="test",workflow_id="2"} 0.008
+`,
+		},
+		{
+			metricName:  "github_actions_workflow_estimated_cost_dollars",
+			mockOptions: defaultMockBehavior,
+			fetcherOpts: []actions.FetcherOpt{
+				actions.WithRepoFilter(actions.RepoFilter{
+					Include: []*regexp.Regexp{regexp.MustCompile("^repo-A$")},
+				}),
+			},
+			collectorOpts: []actions.UsageCollectorOpt{
+				actions.WithPriceTable(actions.PriceTable{"UBUNTU": 0.5}),
+			},
+			wantMetrics: `
+# HELP github_actions_workflow_estimated_cost_dollars Estimated cost in dollars of the billable time for a repo, per workflow and platform
+# TYPE github_actions_workflow_estimated_cost_dollars gauge
+github_actions_workflow_estimated_cost_dollars{owner="totocorp",platform="UBUNTU",repo="repo-A",workflow="build",workflow_id="1"} 0.5
+github_actions_workflow_estimated_cost_dollars{owner="totocorp",platform="UBUNTU",repo="repo-A",workflow="release",workflow_id="3"} 0.5
+github_actions_workflow_estimated_cost_dollars{owner="totocorp",platform="UBUNTU",repo="repo-A",workflow="run",workflow_id="4"} 0.5
+github_actions_workflow_estimated_cost_dollars{owner="totocorp",platform="UBUNTU",repo="repo-A",workflow="test",workflow_id="2"} 0.5
+`,
+		},
 		{
 			metricName:  "github_actions_workflow_billable_time_seconds",
 			mockOptions: defaultMockBehavior,
@@ -162,6 +223,45 @@ github_actions_workflow_last_refresh_timestamp_seconds 1.697328e+09
 github_actions_workflow_active_repos 3
 				`,
 		},
+		{
+			metricName:  "github_actions_workflow_billable_time_seconds",
+			mockOptions: partialFailureMockBehavior,
+			wantMetrics: `
+# HELP github_actions_workflow_billable_time_seconds Billable time for a repo, per workflow and platform
+# TYPE github_actions_workflow_billable_time_seconds gauge
+github_actions_workflow_billable_time_seconds{owner="totocorp",platform="UBUNTU",repo="repo-A",workflow="build",workflow_id="1"} 15
+github_actions_workflow_billable_time_seconds{owner="totocorp",platform="UBUNTU",repo="repo-A",workflow="release",workflow_id="3"} 15
+github_actions_workflow_billable_time_seconds{owner="totocorp",platform="UBUNTU",repo="repo-A",workflow="run",workflow_id="4"} 15
+github_actions_workflow_billable_time_seconds{owner="totocorp",platform="UBUNTU",repo="repo-B",workflow="build",workflow_id="1"} 15
+github_actions_workflow_billable_time_seconds{owner="totocorp",platform="UBUNTU",repo="repo-B",workflow="release",workflow_id="3"} 15
+github_actions_workflow_billable_time_seconds{owner="totocorp",platform="UBUNTU",repo="repo-B",workflow="run",workflow_id="4"} 15
+`,
+		},
+		{
+			metricName:  "github_actions_workflow_fetch_errors_total",
+			mockOptions: partialFailureMockBehavior,
+			wantMetrics: `
+# HELP github_actions_workflow_fetch_errors_total Total of errors encountered while fetching usage data, per repo and stage
+# TYPE github_actions_workflow_fetch_errors_total counter
+github_actions_workflow_fetch_errors_total{owner="totocorp",repo="repo-A",stage="workflow_usage"} 1
+github_actions_workflow_fetch_errors_total{owner="totocorp",repo="repo-B",stage="workflow_usage"} 1
+github_actions_workflow_fetch_errors_total{owner="totocorp",repo="repo-C",stage="list_workflows"} 1
+`,
+		},
+		{
+			metricName:  "github_actions_workflow_filtered_repos",
+			mockOptions: defaultMockBehavior,
+			fetcherOpts: []actions.FetcherOpt{
+				actions.WithRepoFilter(actions.RepoFilter{
+					Exclude: []*regexp.Regexp{regexp.MustCompile("^repo-B$")},
+				}),
+			},
+			wantMetrics: `
+# HELP github_actions_workflow_filtered_repos Last reported total of active repositories ignored by the repository filters, per reason
+# TYPE github_actions_workflow_filtered_repos gauge
+github_actions_workflow_filtered_repos{owner="totocorp",reason="excluded"} 1
+`,
+		},
 	} {
 		t.Run(testCase.metricName, func(t *testing.T) {
 			var (
@@ -172,17 +272,23 @@ github_actions_workflow_active_repos 3
 					"totocorp",
 					gh,
 					logger,
+					testCase.fetcherOpts...,
 				)
 				collector = actions.NewUsageCollector(
 					fetcher,
 					logger,
 					10*time.Minute,
-					actions.WithSinceFunc(
-						fixedSince(time.Second),
-					),
-					actions.WithNowFunc(
-						fixedNow(now),
-					),
+					append(
+						[]actions.UsageCollectorOpt{
+							actions.WithSinceFunc(
+								fixedSince(time.Second),
+							),
+							actions.WithNowFunc(
+								fixedNow(now),
+							),
+						},
+						testCase.collectorOpts...,
+					)...,
 				)
 				registry = prometheus.NewRegistry()
 			)
@@ -204,6 +310,26 @@ github_actions_workflow_active_repos 3
 
 func ptr[V any](v V) *V { return &v }
 
+func paginatedHandler(pages ...any) http.Handler {
+	responsePages := make([][]byte, len(pages))
+	for i, page := range pages {
+		responsePages[i] = mock.MustMarshal(page)
+	}
+
+	return &mock.PaginatedReponseHandler{ResponsePages: responsePages}
+}
+
+func failingHandler(pathFragment string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, pathFragment) {
+			mock.WriteError(w, http.StatusInternalServerError, "boom")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func fixedNow(t time.Time) func() time.Time {
 	return func() time.Time {
 		return t
@@ -0,0 +1,70 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"time"
+)
+
+// PriceTable holds the price in dollars of a minute of billable time, per platform.
+type PriceTable map[string]float64
+
+// DefaultPriceTable matches GitHub's published per-minute rates for standard hosted runners.
+var DefaultPriceTable = PriceTable{
+	"UBUNTU":  0.008,
+	"WINDOWS": 0.016,
+	"MACOS":   0.08,
+}
+
+// LoadPriceTable reads a JSON object mapping platforms to per-minute prices.
+// Platforms missing from the file keep their default price.
+func LoadPriceTable(path string) (PriceTable, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var overrides PriceTable
+	if err := json.Unmarshal(content, &overrides); err != nil {
+		return nil, fmt.Errorf("invalid price table %q: %w", path, err)
+	}
+
+	table, err := DefaultPriceTable.Merge(overrides)
+	if err != nil {
+		return nil, fmt.Errorf("invalid price table %q: %w", path, err)
+	}
+
+	return table, nil
+}
+
+// Merge returns a copy of the table where the prices of overrides replace the existing ones.
+func (t PriceTable) Merge(overrides PriceTable) (PriceTable, error) {
+	table := make(PriceTable, len(t)+len(overrides))
+
+	for platform, price := range t {
+		table[platform] = price
+	}
+
+	for platform, price := range overrides {
+		if price < 0 {
+			return nil, fmt.Errorf("negative price for platform %q", platform)
+		}
+
+		table[platform] = price
+	}
+
+	return table, nil
+}
+
+// EstimateCost returns the cost of a billable time, rounded up to the next minute as GitHub does.
+// GitHub rounds each job individually, as billable time is only known per workflow the estimate can be slightly lower.
+func (t PriceTable) EstimateCost(platform string, billableTime time.Duration) (float64, bool) {
+	price, ok := t[platform]
+	if !ok {
+		return 0, false
+	}
+
+	return math.Ceil(billableTime.Minutes()) * price, true
+}
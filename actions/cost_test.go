@@ -0,0 +1,87 @@
+package actions_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jlevesy/workflows-exporter/actions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriceTable_EstimateCost(t *testing.T) {
+	for _, testCase := range []struct {
+		desc         string
+		platform     string
+		billableTime time.Duration
+		wantCost     float64
+		wantOK       bool
+	}{
+		{
+			desc:         "rounds up to the next minute",
+			platform:     "UBUNTU",
+			billableTime: 61 * time.Second,
+			wantCost:     0.016,
+			wantOK:       true,
+		},
+		{
+			desc:         "applies the platform price",
+			platform:     "MACOS",
+			billableTime: 10 * time.Minute,
+			wantCost:     0.8,
+			wantOK:       true,
+		},
+		{
+			desc:         "no billable time costs nothing",
+			platform:     "WINDOWS",
+			billableTime: 0,
+			wantCost:     0,
+			wantOK:       true,
+		},
+		{
+			desc:         "unknown platform",
+			platform:     "SOLARIS",
+			billableTime: time.Minute,
+			wantOK:       false,
+		},
+	} {
+		t.Run(testCase.desc, func(t *testing.T) {
+			cost, ok := actions.DefaultPriceTable.EstimateCost(testCase.platform, testCase.billableTime)
+			assert.Equal(t, testCase.wantOK, ok)
+			assert.InDelta(t, testCase.wantCost, cost, 1e-9)
+		})
+	}
+}
+
+func TestLoadPriceTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.json")
+
+	err := os.WriteFile(path, []byte(`{"UBUNTU": 0.004, "UBUNTU_16_CORE": 0.064}`), 0o600)
+	require.NoError(t, err)
+
+	table, err := actions.LoadPriceTable(path)
+	require.NoError(t, err)
+
+	assert.Equal(
+		t,
+		actions.PriceTable{
+			"UBUNTU":         0.004,
+			"UBUNTU_16_CORE": 0.064,
+			"WINDOWS":        0.016,
+			"MACOS":          0.08,
+		},
+		table,
+	)
+}
+
+func TestLoadPriceTable_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.json")
+
+	err := os.WriteFile(path, []byte(`{"UBUNTU": -1}`), 0o600)
+	require.NoError(t, err)
+
+	_, err = actions.LoadPriceTable(path)
+	require.Error(t, err)
+}
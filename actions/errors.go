@@ -0,0 +1,49 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/google/go-github/v57/github"
+)
+
+const (
+	ErrorClassRateLimited = "rate_limited"
+	ErrorClassAuth        = "auth"
+	ErrorClassNetwork     = "network"
+	ErrorClassAPI5xx      = "api_5xx"
+	ErrorClassCanceled    = "canceled"
+	ErrorClassOther       = "other"
+)
+
+// ClassifyError tells why a call to the GitHub API failed, to be used as a metric label.
+func ClassifyError(err error) string {
+	var (
+		rateLimitErr      *github.RateLimitError
+		abuseRateLimitErr *github.AbuseRateLimitError
+		responseErr       *github.ErrorResponse
+		netErr            net.Error
+	)
+
+	switch {
+	case errors.As(err, &rateLimitErr), errors.As(err, &abuseRateLimitErr):
+		return ErrorClassRateLimited
+	case errors.As(err, &responseErr) && responseErr.Response != nil:
+		switch code := responseErr.Response.StatusCode; {
+		case code == http.StatusUnauthorized, code == http.StatusForbidden:
+			return ErrorClassAuth
+		case code == http.StatusTooManyRequests:
+			return ErrorClassRateLimited
+		case code >= http.StatusInternalServerError:
+			return ErrorClassAPI5xx
+		}
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassCanceled
+	case errors.As(err, &netErr):
+		return ErrorClassNetwork
+	}
+
+	return ErrorClassOther
+}
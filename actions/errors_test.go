@@ -0,0 +1,72 @@
+package actions_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v57/github"
+	"github.com/jlevesy/workflows-exporter/actions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	responseError := func(code int) error {
+		return &github.ErrorResponse{Response: &http.Response{StatusCode: code}}
+	}
+
+	for _, testCase := range []struct {
+		desc      string
+		err       error
+		wantClass string
+	}{
+		{
+			desc:      "rate limited",
+			err:       &github.RateLimitError{},
+			wantClass: actions.ErrorClassRateLimited,
+		},
+		{
+			desc:      "secondary rate limited",
+			err:       fmt.Errorf("listing repos: %w", &github.AbuseRateLimitError{}),
+			wantClass: actions.ErrorClassRateLimited,
+		},
+		{
+			desc:      "unauthorized",
+			err:       responseError(http.StatusUnauthorized),
+			wantClass: actions.ErrorClassAuth,
+		},
+		{
+			desc:      "forbidden",
+			err:       responseError(http.StatusForbidden),
+			wantClass: actions.ErrorClassAuth,
+		},
+		{
+			desc:      "server error",
+			err:       errors.Join(errors.New("boom"), responseError(http.StatusBadGateway)),
+			wantClass: actions.ErrorClassAPI5xx,
+		},
+		{
+			desc:      "not found",
+			err:       responseError(http.StatusNotFound),
+			wantClass: actions.ErrorClassOther,
+		},
+		{
+			desc:      "network",
+			err:       &url.Error{Op: "Get", URL: "https://api.github.com", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}},
+			wantClass: actions.ErrorClassNetwork,
+		},
+		{
+			desc:      "canceled",
+			err:       &url.Error{Op: "Get", URL: "https://api.github.com", Err: context.Canceled},
+			wantClass: actions.ErrorClassCanceled,
+		},
+	} {
+		t.Run(testCase.desc, func(t *testing.T) {
+			assert.Equal(t, testCase.wantClass, actions.ClassifyError(testCase.err))
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Fetcher retrieves a dataset from the GitHub API.
+type Fetcher[T any] interface {
+	Fetch(ctx context.Context) (*T, error)
+}
+
+// RepoFetcher retrieves the part of a dataset belonging to a single repository.
+type RepoFetcher[T any] interface {
+	FetchRepo(ctx context.Context, owner, repo string) (*T, error)
+}
+
+var (
+	// ErrUnknownOwner is returned when asking for a repository of an organization which is not monitored.
+	ErrUnknownOwner = errors.New("unknown owner")
+	// ErrRepoNotCollected is returned when asking for a repository which is inactive or filtered out.
+	ErrRepoNotCollected = errors.New("repo not collected")
+)
+
+const (
+	StageListRepos     = "list_repos"
+	StageFetchRepo     = "fetch_repo"
+	StageListWorkflows = "list_workflows"
+	StageWorkflowUsage = "workflow_usage"
+	StageListRuns      = "list_runs"
+	StageListJobs      = "list_jobs"
+	StageCacheUsage    = "cache_usage"
+	StageListArtifacts = "list_artifacts"
+
+	StageListRunnerGroups = "list_runner_groups"
+	StageListRunners      = "list_runners"
+)
+
+type FetchError struct {
+	Owner    string
+	Repo     string
+	Workflow string
+	Stage    string
+
+	Err error
+}
+
+func (e FetchError) Error() string {
+	return fmt.Sprintf(
+		"%s failed for owner %q, repo %q, workflow %q: %v",
+		e.Stage,
+		e.Owner,
+		e.Repo,
+		e.Workflow,
+		e.Err,
+	)
+}
+
+func (e FetchError) Unwrap() error { return e.Err }
+
+const (
+	defaultRepoConcurrency     = 10
+	defaultWorkflowConcurrency = 20
+)
+
+// FetcherOpt configures how an organization fetcher scans the GitHub API.
+type FetcherOpt func(c *fetcherConfig)
+
+// WithRepoConcurrency bounds how many repositories can be scanned at the same time.
+func WithRepoConcurrency(n int) FetcherOpt {
+	return func(c *fetcherConfig) {
+		c.repoConcurrency = n
+	}
+}
+
+// WithWorkflowConcurrency bounds how many per workflow calls can be in flight at the same time.
+func WithWorkflowConcurrency(n int) FetcherOpt {
+	return func(c *fetcherConfig) {
+		c.workflowConcurrency = n
+	}
+}
+
+// WithRepoFilter only collects data for the active repositories matching the filter.
+func WithRepoFilter(filter RepoFilter) FetcherOpt {
+	return func(c *fetcherConfig) {
+		c.repoFilter = filter
+	}
+}
+
+// WithWorkflowJobs also lists the jobs of each workflow run, at the cost of one more API call per run.
+func WithWorkflowJobs() FetcherOpt {
+	return func(c *fetcherConfig) {
+		c.collectJobs = true
+	}
+}
+
+// WithIncrementalRefresh only fetches again the workflows usage of the repositories pushed since the previous fetch,
+// or fetched more than maxAge ago. The others are served from the previous fetch. Only the usage fetcher supports it.
+func WithIncrementalRefresh(maxAge time.Duration) FetcherOpt {
+	return func(c *fetcherConfig) {
+		c.incrementalMaxAge = maxAge
+	}
+}
+
+type fetcherConfig struct {
+	repoConcurrency     int
+	workflowConcurrency int
+	repoFilter          RepoFilter
+	collectJobs         bool
+	incrementalMaxAge   time.Duration
+}
+
+func newFetcherConfig(opts []FetcherOpt) fetcherConfig {
+	config := fetcherConfig{
+		repoConcurrency:     defaultRepoConcurrency,
+		workflowConcurrency: defaultWorkflowConcurrency,
+	}
+
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	return config
+}
@@ -0,0 +1,198 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v57/github"
+)
+
+const (
+	FilterReasonNotIncluded    = "not_included"
+	FilterReasonExcluded       = "excluded"
+	FilterReasonMissingTopic   = "missing_topic"
+	FilterReasonForbiddenTopic = "forbidden_topic"
+	FilterReasonVisibility     = "visibility"
+	FilterReasonArchived       = "archived"
+	FilterReasonFork           = "fork"
+)
+
+// RepoFilter scopes the collection to a subset of the active repositories.
+// A zero RepoFilter lets everything through.
+type RepoFilter struct {
+	// Include keeps only the repositories whose name matches at least one pattern, if not empty.
+	Include []*regexp.Regexp
+	// Exclude drops the repositories whose name matches any pattern.
+	Exclude []*regexp.Regexp
+
+	// RequiredTopics keeps only repositories having all the given topics.
+	RequiredTopics []string
+	// ForbiddenTopics drops repositories having any of the given topics.
+	ForbiddenTopics []string
+
+	// Visibilities keeps only repositories with one of the given visibility (public, private, internal), if not empty.
+	Visibilities []string
+
+	SkipArchived bool
+	SkipForks    bool
+}
+
+// RepoVisibilities are the visibilities a repository can have.
+var RepoVisibilities = []string{"public", "private", "internal"}
+
+// RepoFilterSpec holds the settings of a RepoFilter before they are parsed.
+type RepoFilterSpec struct {
+	Include         []string
+	Exclude         []string
+	RequiredTopics  []string
+	ForbiddenTopics []string
+	Visibilities    []string
+	SkipArchived    bool
+	SkipForks       bool
+}
+
+// ParseRepoFilter validates the spec and builds its filter. Every invalid setting is reported, prefixed by its name.
+func ParseRepoFilter(spec RepoFilterSpec) (RepoFilter, error) {
+	var errs []error
+
+	include, err := ParseRepoPatterns(spec.Include)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("include: %w", err))
+	}
+
+	exclude, err := ParseRepoPatterns(spec.Exclude)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("exclude: %w", err))
+	}
+
+	for _, visibility := range spec.Visibilities {
+		if !slices.Contains(RepoVisibilities, visibility) {
+			errs = append(errs, fmt.Errorf("visibility: unknown visibility %q, must be one of %s", visibility, strings.Join(RepoVisibilities, ", ")))
+		}
+	}
+
+	if len(errs) > 0 {
+		return RepoFilter{}, errors.Join(errs...)
+	}
+
+	return RepoFilter{
+		Include:         include,
+		Exclude:         exclude,
+		RequiredTopics:  spec.RequiredTopics,
+		ForbiddenTopics: spec.ForbiddenTopics,
+		Visibilities:    spec.Visibilities,
+		SkipArchived:    spec.SkipArchived,
+		SkipForks:       spec.SkipForks,
+	}, nil
+}
+
+// Match tells if the repository should be collected, if not it returns the reason why it has been filtered.
+func (f RepoFilter) Match(repo *github.Repository) (string, bool) {
+	name := repo.GetName()
+
+	if len(f.Include) > 0 && !matchAny(f.Include, name) {
+		return FilterReasonNotIncluded, false
+	}
+
+	if matchAny(f.Exclude, name) {
+		return FilterReasonExcluded, false
+	}
+
+	if f.SkipArchived && repo.GetArchived() {
+		return FilterReasonArchived, false
+	}
+
+	if f.SkipForks && repo.GetFork() {
+		return FilterReasonFork, false
+	}
+
+	if len(f.Visibilities) > 0 && !slices.Contains(f.Visibilities, repo.GetVisibility()) {
+		return FilterReasonVisibility, false
+	}
+
+	for _, topic := range f.RequiredTopics {
+		if !slices.Contains(repo.Topics, topic) {
+			return FilterReasonMissingTopic, false
+		}
+	}
+
+	for _, topic := range f.ForbiddenTopics {
+		if slices.Contains(repo.Topics, topic) {
+			return FilterReasonForbiddenTopic, false
+		}
+	}
+
+	return "", true
+}
+
+// ParseRepoPatterns compiles repository name patterns. A pattern wrapped in slashes
+// is a regular expression (/^team-.*$/), otherwise it is a glob (team-*).
+func ParseRepoPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	result := make([]*regexp.Regexp, 0, len(patterns))
+
+	for _, pattern := range patterns {
+		expr := globToRegexp(pattern)
+		if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			expr = pattern[1 : len(pattern)-1]
+		}
+
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid repository pattern %q: %w", pattern, err)
+		}
+
+		result = append(result, re)
+	}
+
+	return result, nil
+}
+
+// SplitList splits a comma separated list, trimming spaces and dropping empty items.
+func SplitList(v string) []string {
+	var result []string
+
+	for _, item := range strings.Split(v, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		result = append(result, item)
+	}
+
+	return result
+}
+
+func globToRegexp(glob string) string {
+	var b strings.Builder
+
+	b.WriteString("^")
+
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	b.WriteString("$")
+
+	return b.String()
+}
+
+func matchAny(patterns []*regexp.Regexp, name string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(name) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,131 @@
+package actions_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/google/go-github/v57/github"
+	"github.com/jlevesy/workflows-exporter/actions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepoFilter_Match(t *testing.T) {
+	mustParse := func(patterns ...string) []*regexp.Regexp {
+		result, err := actions.ParseRepoPatterns(patterns)
+		require.NoError(t, err)
+		return result
+	}
+
+	for _, testCase := range []struct {
+		desc       string
+		filter     actions.RepoFilter
+		repo       *github.Repository
+		wantReason string
+		wantOK     bool
+	}{
+		{
+			desc:   "zero filter matches everything",
+			repo:   &github.Repository{Name: ptr("repo-A")},
+			wantOK: true,
+		},
+		{
+			desc:   "included by glob",
+			filter: actions.RepoFilter{Include: mustParse("team-*")},
+			repo:   &github.Repository{Name: ptr("team-api")},
+			wantOK: true,
+		},
+		{
+			desc:       "not included by glob",
+			filter:     actions.RepoFilter{Include: mustParse("team-*")},
+			repo:       &github.Repository{Name: ptr("other-team-api")},
+			wantReason: actions.FilterReasonNotIncluded,
+		},
+		{
+			desc:       "excluded by regexp",
+			filter:     actions.RepoFilter{Exclude: mustParse("/-(sandbox|tmp)$/")},
+			repo:       &github.Repository{Name: ptr("api-sandbox")},
+			wantReason: actions.FilterReasonExcluded,
+		},
+		{
+			desc:       "archived",
+			filter:     actions.RepoFilter{SkipArchived: true},
+			repo:       &github.Repository{Name: ptr("repo-A"), Archived: ptr(true)},
+			wantReason: actions.FilterReasonArchived,
+		},
+		{
+			desc:       "fork",
+			filter:     actions.RepoFilter{SkipForks: true},
+			repo:       &github.Repository{Name: ptr("repo-A"), Fork: ptr(true)},
+			wantReason: actions.FilterReasonFork,
+		},
+		{
+			desc:       "visibility",
+			filter:     actions.RepoFilter{Visibilities: []string{"private", "internal"}},
+			repo:       &github.Repository{Name: ptr("repo-A"), Visibility: ptr("public")},
+			wantReason: actions.FilterReasonVisibility,
+		},
+		{
+			desc:       "missing topic",
+			filter:     actions.RepoFilter{RequiredTopics: []string{"production", "backend"}},
+			repo:       &github.Repository{Name: ptr("repo-A"), Topics: []string{"production"}},
+			wantReason: actions.FilterReasonMissingTopic,
+		},
+		{
+			desc:       "forbidden topic",
+			filter:     actions.RepoFilter{ForbiddenTopics: []string{"deprecated"}},
+			repo:       &github.Repository{Name: ptr("repo-A"), Topics: []string{"backend", "deprecated"}},
+			wantReason: actions.FilterReasonForbiddenTopic,
+		},
+		{
+			desc: "matches all rules",
+			filter: actions.RepoFilter{
+				Include:         mustParse("repo-*"),
+				Exclude:         mustParse("repo-B"),
+				RequiredTopics:  []string{"backend"},
+				ForbiddenTopics: []string{"deprecated"},
+				Visibilities:    []string{"private"},
+				SkipArchived:    true,
+				SkipForks:       true,
+			},
+			repo: &github.Repository{
+				Name:       ptr("repo-A"),
+				Topics:     []string{"backend"},
+				Visibility: ptr("private"),
+			},
+			wantOK: true,
+		},
+	} {
+		t.Run(testCase.desc, func(t *testing.T) {
+			gotReason, gotOK := testCase.filter.Match(testCase.repo)
+			assert.Equal(t, testCase.wantOK, gotOK)
+			assert.Equal(t, testCase.wantReason, gotReason)
+		})
+	}
+}
+
+func TestParseRepoPatterns_Invalid(t *testing.T) {
+	_, err := actions.ParseRepoPatterns([]string{"/[/"})
+	require.Error(t, err)
+}
+
+func TestParseRepoFilter(t *testing.T) {
+	filter, err := actions.ParseRepoFilter(actions.RepoFilterSpec{
+		Include:      actions.SplitList("team-*, /^infra-/,"),
+		Visibilities: []string{"private"},
+		SkipForks:    true,
+	})
+	require.NoError(t, err)
+
+	reason, ok := filter.Match(&github.Repository{Name: ptr("infra-dns"), Visibility: ptr("private")})
+	assert.True(t, ok, reason)
+	assert.True(t, filter.SkipForks)
+
+	_, err = actions.ParseRepoFilter(actions.RepoFilterSpec{
+		Exclude:      []string{"/[/"},
+		Visibilities: []string{"private", "secret"},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "exclude: invalid repository pattern \"/[/\"")
+	assert.Contains(t, err.Error(), "visibility: unknown visibility \"secret\", must be one of public, private, internal")
+}
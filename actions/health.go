@@ -0,0 +1,92 @@
+package actions
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const (
+	healthStatusReady    = "ready"
+	healthStatusNotReady = "not_ready"
+	healthStatusHealthy  = "healthy"
+	healthStatusStale    = "stale"
+)
+
+// HealthStatus is the JSON body served by the readiness and liveness handlers.
+type HealthStatus struct {
+	Status string `json:"status"`
+
+	LastRefreshTime     *time.Time `json:"last_refresh_time,omitempty"`
+	LastRefreshAge      string     `json:"last_refresh_age,omitempty"`
+	LastRefreshDuration string     `json:"last_refresh_duration,omitempty"`
+	LastRefreshErrors   int        `json:"last_refresh_errors"`
+
+	MaxAge string `json:"max_age,omitempty"`
+}
+
+// ReadyHandler succeeds once a refresh succeeded, or fresh data has been restored from the state file.
+// A failed first refresh, or stale restored data, keeps the collector not ready until a refresh succeeds.
+func (c *UsageCollector) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		status, _, ready := c.healthStatus()
+
+		code := http.StatusOK
+		status.Status = healthStatusReady
+
+		if !ready {
+			code = http.StatusServiceUnavailable
+			status.Status = healthStatusNotReady
+		}
+
+		writeHealthStatus(w, code, status)
+	})
+}
+
+// HealthHandler fails when the last successful refresh is older than maxAge.
+// Until the first refresh completes, the age is counted from the collector creation, so that a slow
+// first refresh on a large organization, or stale data restored from the state file, does not get the
+// exporter restarted in a loop.
+func (c *UsageCollector) HealthHandler(maxAge time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		status, age, _ := c.healthStatus()
+
+		code := http.StatusOK
+		status.Status = healthStatusHealthy
+		status.LastRefreshAge = age.String()
+		status.MaxAge = maxAge.String()
+
+		if age > maxAge {
+			code = http.StatusServiceUnavailable
+			status.Status = healthStatusStale
+		}
+
+		writeHealthStatus(w, code, status)
+	})
+}
+
+func (c *UsageCollector) healthStatus() (HealthStatus, time.Duration, bool) {
+	c.lastUsageDataMu.RLock()
+	defer c.lastUsageDataMu.RUnlock()
+
+	age := c.healthAgeLocked()
+
+	if c.lastUsageData == nil {
+		return HealthStatus{}, age, false
+	}
+
+	lastRefreshTime := c.lastRefreshTime
+
+	return HealthStatus{
+		LastRefreshTime:     &lastRefreshTime,
+		LastRefreshDuration: c.lastRefreshDuration.String(),
+		LastRefreshErrors:   len(c.lastUsageData.Errors),
+	}, age, c.refreshed || c.restoredFresh
+}
+
+func writeHealthStatus(w http.ResponseWriter, code int, status HealthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	_ = json.NewEncoder(w).Encode(status)
+}
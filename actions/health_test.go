@@ -0,0 +1,291 @@
+package actions_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+	"github.com/jlevesy/workflows-exporter/actions"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *testClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = t
+}
+
+func getHealthStatus(t *testing.T, handler http.Handler) (int, actions.HealthStatus) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var status actions.HealthStatus
+	err := json.Unmarshal(rec.Body.Bytes(), &status)
+	require.NoError(t, err)
+
+	return rec.Code, status
+}
+
+func TestUsageCollector_HealthHandlers(t *testing.T) {
+	var (
+		clock   = testClock{now: now}
+		release = make(chan struct{})
+		fetcher = usageFetcherFunc(func(ctx context.Context) (*actions.Usage, error) {
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+
+			return &stateUsage, nil
+		})
+		collector = actions.NewUsageCollector(
+			fetcher,
+			zaptest.NewLogger(t),
+			10*time.Minute,
+			actions.WithNowFunc(clock.Now),
+			actions.WithSinceFunc(fixedSince(time.Second)),
+		)
+		readyHandler  = collector.ReadyHandler()
+		healthHandler = collector.HealthHandler(30 * time.Minute)
+	)
+
+	defer collector.Close()
+
+	// First refresh still in progress.
+	code, status := getHealthStatus(t, readyHandler)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not_ready", status.Status)
+
+	clock.Set(now.Add(20 * time.Minute))
+
+	code, status = getHealthStatus(t, healthHandler)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "healthy", status.Status)
+	assert.Equal(t, "20m0s", status.LastRefreshAge)
+
+	close(release)
+	<-collector.Ready()
+
+	code, status = getHealthStatus(t, readyHandler)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", status.Status)
+	require.NotNil(t, status.LastRefreshTime)
+	assert.True(t, now.Add(20*time.Minute).Equal(*status.LastRefreshTime))
+
+	// Refreshes are now failing or wedged.
+	clock.Set(now.Add(time.Hour))
+
+	code, status = getHealthStatus(t, healthHandler)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "stale", status.Status)
+	assert.Equal(t, "40m0s", status.LastRefreshAge)
+	assert.Equal(t, "30m0s", status.MaxAge)
+}
+
+func TestUsageCollector_ReadyHandler_FirstRefreshFails(t *testing.T) {
+	var (
+		fetches atomic.Int64
+		fetcher = usageFetcherFunc(func(context.Context) (*actions.Usage, error) {
+			if fetches.Add(1) == 1 {
+				return nil, errors.New("could not list repos")
+			}
+
+			return &stateUsage, nil
+		})
+		collector = actions.NewUsageCollector(
+			fetcher,
+			zaptest.NewLogger(t),
+			10*time.Minute,
+			actions.WithNowFunc(fixedNow(now)),
+			actions.WithSinceFunc(fixedSince(time.Second)),
+		)
+		readyHandler = collector.ReadyHandler()
+	)
+
+	defer collector.Close()
+
+	<-collector.Ready()
+
+	// The first refresh is done, but it failed.
+	code, status := getHealthStatus(t, readyHandler)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not_ready", status.Status)
+
+	result := <-collector.TriggerRefresh("", "")
+	require.NoError(t, result.Err)
+
+	code, status = getHealthStatus(t, readyHandler)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", status.Status)
+}
+
+func TestUsageCollector_HealthHandler_RestoredStaleState(t *testing.T) {
+	var (
+		clock     = testClock{now: now}
+		statePath = filepath.Join(t.TempDir(), "state.json")
+		release   = make(chan struct{})
+		fetcher   = usageFetcherFunc(func(ctx context.Context) (*actions.Usage, error) {
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+
+			return &stateUsage, nil
+		})
+	)
+
+	writeState(t, statePath, now.Add(-2*time.Hour))
+
+	collector := actions.NewUsageCollector(
+		fetcher,
+		zaptest.NewLogger(t),
+		10*time.Minute,
+		actions.WithNowFunc(clock.Now),
+		actions.WithSinceFunc(fixedSince(time.Second)),
+		actions.WithStateFile(statePath, 0),
+	)
+
+	defer collector.Close()
+
+	var (
+		readyHandler  = collector.ReadyHandler()
+		healthHandler = collector.HealthHandler(30 * time.Minute)
+	)
+
+	// The restored data is older than the max age, but the first refresh is still in progress.
+	clock.Set(now.Add(5 * time.Minute))
+
+	code, status := getHealthStatus(t, readyHandler)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not_ready", status.Status)
+
+	code, status = getHealthStatus(t, healthHandler)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "healthy", status.Status)
+	assert.Equal(t, "5m0s", status.LastRefreshAge)
+	require.NotNil(t, status.LastRefreshTime)
+	assert.True(t, now.Add(-2*time.Hour).Equal(*status.LastRefreshTime))
+
+	close(release)
+	<-collector.Ready()
+
+	code, status = getHealthStatus(t, readyHandler)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", status.Status)
+
+	clock.Set(now.Add(15 * time.Minute))
+
+	code, status = getHealthStatus(t, healthHandler)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "10m0s", status.LastRefreshAge)
+
+	// First refresh is done, the age is now counted from it.
+	clock.Set(now.Add(time.Hour))
+
+	code, status = getHealthStatus(t, healthHandler)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "stale", status.Status)
+	assert.Equal(t, "55m0s", status.LastRefreshAge)
+}
+
+func TestUsageCollector_RefreshMetrics(t *testing.T) {
+	var (
+		clock   = testClock{now: now}
+		fetcher = usageFetcherFunc(func(context.Context) (*actions.Usage, error) {
+			return nil, fmt.Errorf("could not list repos: %w", &github.RateLimitError{})
+		})
+		collector = actions.NewUsageCollector(
+			fetcher,
+			zaptest.NewLogger(t),
+			10*time.Minute,
+			actions.WithNowFunc(clock.Now),
+			actions.WithSinceFunc(fixedSince(time.Second)),
+			actions.WithStaleThreshold(30*time.Minute),
+		)
+		registry    = prometheus.NewRegistry()
+		metricNames = []string{
+			"github_actions_workflow_refresh_attempts_total",
+			"github_actions_workflow_refresh_successes_total",
+			"github_actions_workflow_refresh_failures_total",
+			"github_actions_workflow_last_refresh_failure_timestamp_seconds",
+			"github_actions_workflow_data_stale",
+		}
+	)
+
+	defer collector.Close()
+
+	err := registry.Register(collector)
+	require.NoError(t, err)
+
+	<-collector.Ready()
+
+	err = testutil.GatherAndCompare(
+		registry,
+		bytes.NewBufferString(`
+# HELP github_actions_workflow_refresh_attempts_total Total of usage data refreshes attempted
+# TYPE github_actions_workflow_refresh_attempts_total counter
+github_actions_workflow_refresh_attempts_total 1
+# HELP github_actions_workflow_refresh_successes_total Total of usage data refreshes that succeeded, possibly with partial data
+# TYPE github_actions_workflow_refresh_successes_total counter
+github_actions_workflow_refresh_successes_total 0
+# HELP github_actions_workflow_refresh_failures_total Total of usage data refreshes that failed, per error class
+# TYPE github_actions_workflow_refresh_failures_total counter
+github_actions_workflow_refresh_failures_total{class="rate_limited"} 1
+# HELP github_actions_workflow_last_refresh_failure_timestamp_seconds Timestamp in seconds since epoch of the last failed usage data refresh
+# TYPE github_actions_workflow_last_refresh_failure_timestamp_seconds gauge
+github_actions_workflow_last_refresh_failure_timestamp_seconds 1.69732800e+09
+# HELP github_actions_workflow_data_stale Whether the last successful refresh is older than the staleness threshold
+# TYPE github_actions_workflow_data_stale gauge
+github_actions_workflow_data_stale 0
+`),
+		metricNames...,
+	)
+	require.NoError(t, err)
+
+	// Still no data past the threshold.
+	clock.Set(now.Add(time.Hour))
+
+	err = testutil.GatherAndCompare(
+		registry,
+		bytes.NewBufferString(`
+# HELP github_actions_workflow_data_stale Whether the last successful refresh is older than the staleness threshold
+# TYPE github_actions_workflow_data_stale gauge
+github_actions_workflow_data_stale 1
+`),
+		"github_actions_workflow_data_stale",
+	)
+	require.NoError(t, err)
+}
@@ -0,0 +1,78 @@
+package actions
+
+import (
+	"sort"
+	"strings"
+)
+
+// DefaultDurationBuckets covers durations from 10 seconds to 2 hours, in seconds.
+var DefaultDurationBuckets = []float64{10, 30, 60, 120, 300, 600, 1200, 1800, 3600, 7200}
+
+// histogram accumulates observations to be exposed as a const histogram.
+type histogram struct {
+	count   uint64
+	sum     float64
+	buckets map[float64]uint64
+}
+
+func newHistogram(upperBounds []float64) *histogram {
+	h := histogram{
+		buckets: make(map[float64]uint64, len(upperBounds)),
+	}
+
+	for _, bound := range upperBounds {
+		h.buckets[bound] = 0
+	}
+
+	return &h
+}
+
+func (h *histogram) observe(v float64) {
+	h.count++
+	h.sum += v
+
+	// Prometheus expects cumulative bucket counts.
+	for bound := range h.buckets {
+		if v <= bound {
+			h.buckets[bound]++
+		}
+	}
+}
+
+type histogramKey struct {
+	owner        string
+	repo         string
+	workflow     string
+	runnerLabels string
+}
+
+// histogramSet holds one histogram per label set, all sharing the same buckets.
+type histogramSet struct {
+	upperBounds []float64
+	histograms  map[histogramKey]*histogram
+}
+
+func newHistogramSet(upperBounds []float64) histogramSet {
+	return histogramSet{
+		upperBounds: upperBounds,
+		histograms:  make(map[histogramKey]*histogram),
+	}
+}
+
+func (s histogramSet) observe(key histogramKey, v float64) {
+	h, ok := s.histograms[key]
+	if !ok {
+		h = newHistogram(s.upperBounds)
+		s.histograms[key] = h
+	}
+
+	h.observe(v)
+}
+
+// joinRunnerLabels makes a stable label value out of the labels requested by a job.
+func joinRunnerLabels(labels []string) string {
+	sorted := append([]string(nil), labels...)
+	sort.Strings(sorted)
+
+	return strings.Join(sorted, ",")
+}
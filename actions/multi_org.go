@@ -0,0 +1,144 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// MultiOrgFetcher fans out Fetch calls to one fetcher per organization, and merges their results.
+// A failure for one organization does not prevent the others from being reported.
+type MultiOrgFetcher[T any] struct {
+	fetchers map[string]Fetcher[T]
+	logger   *zap.Logger
+
+	merge    func(into, from *T)
+	addError func(into *T, fetchErr FetchError)
+}
+
+func NewMultiOrgUsageFetcher(fetchers map[string]WorkflowUsageFetcher, logger *zap.Logger) *MultiOrgFetcher[Usage] {
+	return &MultiOrgFetcher[Usage]{
+		fetchers: fetchers,
+		logger:   logger,
+		merge:    (*Usage).merge,
+		addError: (*Usage).addError,
+	}
+}
+
+func (f *MultiOrgFetcher[T]) Fetch(ctx context.Context) (*T, error) {
+	var (
+		wg sync.WaitGroup
+
+		resultsMu sync.Mutex
+		result    T
+		errs      []error
+	)
+
+	for org, fetcher := range f.fetchers {
+		org, fetcher := org, fetcher
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			orgResult, err := fetcher.Fetch(ctx)
+			if err != nil {
+				f.logger.Error(
+					"Could not retrieve data for organization",
+					zap.String("owner", org),
+					zap.Error(err),
+				)
+
+				resultsMu.Lock()
+				errs = append(errs, fmt.Errorf("organization %q: %w", org, err))
+				f.addError(&result, FetchError{
+					Owner: org,
+					Stage: StageListRepos,
+					Err:   err,
+				})
+				resultsMu.Unlock()
+
+				return
+			}
+
+			resultsMu.Lock()
+			f.merge(&result, orgResult)
+			resultsMu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	// Only report an error if nothing could be collected at all,
+	// otherwise serve what we have for the healthy organizations.
+	if len(errs) == len(f.fetchers) && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return &result, nil
+}
+
+// FetchRepo forwards the call to the fetcher of the repo owner, if it supports fetching a single repository.
+func (f *MultiOrgFetcher[T]) FetchRepo(ctx context.Context, owner, repo string) (*T, error) {
+	for org, fetcher := range f.fetchers {
+		if !strings.EqualFold(org, owner) {
+			continue
+		}
+
+		repoFetcher, ok := fetcher.(RepoFetcher[T])
+		if !ok {
+			return nil, fmt.Errorf("organization %q does not support fetching a single repo", org)
+		}
+
+		return repoFetcher.FetchRepo(ctx, org, repo)
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrUnknownOwner, owner)
+}
+
+// ListRepos merges the repositories listed for each organization, if their fetchers support it.
+// A failure for one organization is reported in the errors of the list.
+func (f *MultiOrgFetcher[T]) ListRepos(ctx context.Context) (*RepoList, error) {
+	var (
+		result RepoList
+		errs   []error
+	)
+
+	for org, fetcher := range f.fetchers {
+		lister, ok := fetcher.(RepoLister)
+		if !ok {
+			return nil, fmt.Errorf("organization %q does not support listing repos", org)
+		}
+
+		orgList, err := lister.ListRepos(ctx)
+		if err != nil {
+			f.logger.Error(
+				"Could not list repositories for organization",
+				zap.String("owner", org),
+				zap.Error(err),
+			)
+
+			errs = append(errs, fmt.Errorf("organization %q: %w", org, err))
+			result.Errors = append(result.Errors, FetchError{
+				Owner: org,
+				Stage: StageListRepos,
+				Err:   err,
+			})
+
+			continue
+		}
+
+		result.merge(orgList)
+	}
+
+	if len(errs) == len(f.fetchers) && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,103 @@
+package actions_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+	"github.com/jlevesy/workflows-exporter/actions"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestMultiOrgUsageFetcher(t *testing.T) {
+	reposHandler := func(failingOrgs ...string) mock.MockBackendOption {
+		handler := paginatedHandler(repos...)
+		for _, org := range failingOrgs {
+			handler = failingHandler("/orgs/"+org+"/", handler)
+		}
+
+		return mock.WithRequestMatchHandler(mock.GetOrgsReposByOrg, handler)
+	}
+
+	for _, testCase := range []struct {
+		desc            string
+		failingOrgs     []string
+		wantErr         bool
+		wantActiveRepos int64
+		wantOwners      []string
+		wantErrors      []actions.FetchError
+	}{
+		{
+			desc:            "all organizations succeed",
+			wantActiveRepos: 6,
+			wantOwners:      []string{"totocorp", "tatacorp"},
+		},
+		{
+			desc:            "one organization fails",
+			failingOrgs:     []string{"tatacorp"},
+			wantActiveRepos: 3,
+			wantOwners:      []string{"totocorp"},
+			wantErrors: []actions.FetchError{
+				{Owner: "tatacorp", Stage: actions.StageListRepos},
+			},
+		},
+		{
+			desc:        "all organizations fail",
+			failingOrgs: []string{"totocorp", "tatacorp"},
+			wantErr:     true,
+		},
+	} {
+		t.Run(testCase.desc, func(t *testing.T) {
+			var (
+				logger = zaptest.NewLogger(t)
+				gh     = github.NewClient(
+					mock.NewMockedHTTPClient(
+						reposHandler(testCase.failingOrgs...),
+						mock.WithRequestMatchPages(mock.GetReposActionsWorkflowsByOwnerByRepo, workflows...),
+						mock.WithRequestMatchPages(
+							mock.GetReposActionsWorkflowsTimingByOwnerByRepoByWorkflowId,
+							workflowTiming,
+						),
+					),
+				)
+				fetcher = actions.NewMultiOrgUsageFetcher(
+					map[string]actions.WorkflowUsageFetcher{
+						"totocorp": actions.NewOrgUsageFetcher(24*time.Hour, "totocorp", gh, logger),
+						"tatacorp": actions.NewOrgUsageFetcher(24*time.Hour, "tatacorp", gh, logger),
+					},
+					logger,
+				)
+			)
+
+			usage, err := fetcher.Fetch(context.Background())
+			if testCase.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testCase.wantActiveRepos, usage.ActiveRepos)
+
+			gotOwners := make(map[string]int)
+			for _, workflow := range usage.Workflows {
+				gotOwners[workflow.Owner]++
+			}
+
+			require.Len(t, gotOwners, len(testCase.wantOwners))
+			for _, owner := range testCase.wantOwners {
+				assert.Equal(t, 12, gotOwners[owner])
+			}
+
+			require.Len(t, usage.Errors, len(testCase.wantErrors))
+			for i, wantErr := range testCase.wantErrors {
+				assert.Equal(t, wantErr.Owner, usage.Errors[i].Owner)
+				assert.Equal(t, wantErr.Stage, usage.Errors[i].Stage)
+				assert.Error(t, usage.Errors[i].Err)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package actions
+
+import (
+	"context"
+	"time"
+)
+
+// refresher drives the refreshes of a collector.
+type refresher interface {
+	Close() error
+	Ready() <-chan struct{}
+	// Context is cancelled once the refresher is closed, refreshes triggered out of the refresher should use it.
+	Context() context.Context
+}
+
+// refreshLoop calls refresh once right away, then every period until closed.
+// Ready is closed once the first refresh is done.
+type refreshLoop struct {
+	refreshTicker *time.Ticker
+	ctx           context.Context
+	cancelFunc    func()
+	ready         chan struct{}
+}
+
+func startRefreshLoop(period time.Duration, refresh func(ctx context.Context)) *refreshLoop {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	l := refreshLoop{
+		refreshTicker: time.NewTicker(period),
+		ctx:           ctx,
+		cancelFunc:    cancel,
+		ready:         make(chan struct{}),
+	}
+
+	go func() {
+		refresh(ctx)
+
+		close(l.ready)
+
+		l.run(ctx, refresh)
+	}()
+
+	return &l
+}
+
+// startDelayedRefreshLoop is used when data is already available, typically restored from disk.
+// Ready is closed right away, and the first refresh happens after delay.
+func startDelayedRefreshLoop(delay, period time.Duration, refresh func(ctx context.Context)) *refreshLoop {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	l := refreshLoop{
+		refreshTicker: time.NewTicker(period),
+		ctx:           ctx,
+		cancelFunc:    cancel,
+		ready:         make(chan struct{}),
+	}
+
+	// Ticking only starts after the first refresh.
+	l.refreshTicker.Stop()
+
+	close(l.ready)
+
+	go func() {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		refresh(ctx)
+
+		l.refreshTicker.Reset(period)
+
+		l.run(ctx, refresh)
+	}()
+
+	return &l
+}
+
+func (l *refreshLoop) run(ctx context.Context, refresh func(ctx context.Context)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-l.refreshTicker.C:
+			refresh(ctx)
+		}
+	}
+}
+
+func (l *refreshLoop) Close() error {
+	l.cancelFunc()
+	l.refreshTicker.Stop()
+
+	return nil
+}
+
+func (l *refreshLoop) Context() context.Context {
+	return l.ctx
+}
+
+func (l *refreshLoop) Ready() <-chan struct{} {
+	return l.ready
+}
@@ -0,0 +1,188 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+	"go.uber.org/zap"
+)
+
+type FilteredRepos struct {
+	Owner  string
+	Reason string
+	Count  int64
+}
+
+type repoScanResult struct {
+	activeRepos   int64
+	filteredRepos []FilteredRepos
+}
+
+// repoScanner discovers the active repositories of an organization,
+// that is repositories pushed recently enough and matching the filter.
+type repoScanner struct {
+	org           string
+	gh            *github.Client
+	maxLastPushed time.Duration
+	filter        RepoFilter
+	logger        *zap.Logger
+}
+
+// scan calls cb for every active repository matching the filter, sequentially.
+func (s *repoScanner) scan(ctx context.Context, cb func(*github.Repository)) (repoScanResult, error) {
+	var (
+		result   repoScanResult
+		filtered = make(map[string]int64)
+	)
+
+	err := scanAllOrgRepos(
+		ctx,
+		s.org,
+		s.gh.Repositories,
+		func(reposBatch []*github.Repository) error {
+			var totalInactive int
+
+			s.logger.Info(
+				"New batch of repositories",
+				zap.String("owner", s.org),
+				zap.Int("length", len(reposBatch)),
+			)
+
+			for _, repo := range reposBatch {
+				if !s.isActive(repo) {
+					totalInactive++
+					continue
+				}
+
+				result.activeRepos++
+
+				if reason, ok := s.filter.Match(repo); !ok {
+					s.logger.Debug(
+						"Skipping filtered repository",
+						zap.String("owner", s.org),
+						zap.String("repo", repo.GetName()),
+						zap.String("reason", reason),
+					)
+
+					filtered[reason]++
+					continue
+				}
+
+				cb(repo)
+			}
+
+			// Don't scan for all repos, if all are inactive in a single batch
+			// and because we're scanning in pushed_at descending order
+			// then we can consider the job done.
+			if totalInactive == len(reposBatch) {
+				s.logger.Info("Got a full batch of inactive repositories, exiting")
+				return errEarlyExit
+			}
+
+			return nil
+		},
+	)
+	if err != nil {
+		return result, err
+	}
+
+	for reason, count := range filtered {
+		result.filteredRepos = append(
+			result.filteredRepos,
+			FilteredRepos{Owner: s.org, Reason: reason, Count: count},
+		)
+	}
+
+	return result, nil
+}
+
+func (s *repoScanner) isActive(repo *github.Repository) bool {
+	return time.Since(repo.GetPushedAt().Time) < s.maxLastPushed
+}
+
+// get retrieves a single repository, and makes sure that it is collected by this scanner.
+func (s *repoScanner) get(ctx context.Context, name string) (*github.Repository, error) {
+	repo, _, err := s.gh.Repositories.Get(ctx, s.org, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.isActive(repo) {
+		return nil, fmt.Errorf("%w: repo %q is inactive", ErrRepoNotCollected, name)
+	}
+
+	if reason, ok := s.filter.Match(repo); !ok {
+		return nil, fmt.Errorf("%w: repo %q is filtered out (%s)", ErrRepoNotCollected, name, reason)
+	}
+
+	return repo, nil
+}
+
+func scanAllRepoWorkflows(ctx context.Context, org, repo string, workflowClient *github.ActionsService, cb func(*github.Workflows)) error {
+	var nextPage int
+
+	for {
+		workflowBatch, resp, err := workflowClient.ListWorkflows(
+			ctx,
+			org,
+			repo,
+			&github.ListOptions{
+				Page:    nextPage,
+				PerPage: 10,
+			},
+		)
+
+		if err != nil {
+			return err
+		}
+
+		cb(workflowBatch)
+
+		if resp.NextPage == 0 {
+			return nil
+		}
+
+		nextPage = resp.NextPage
+	}
+}
+
+var errEarlyExit = errors.New("early exit")
+
+func scanAllOrgRepos(ctx context.Context, org string, reposClient *github.RepositoriesService, cb func([]*github.Repository) error) error {
+	var nextPage int
+
+	for {
+		reposBatch, resp, err := reposClient.ListByOrg(
+			ctx,
+			org,
+			&github.RepositoryListByOrgOptions{
+				Sort:      "pushed",
+				Direction: "desc",
+				ListOptions: github.ListOptions{
+					Page:    nextPage,
+					PerPage: 100,
+				},
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		err = cb(reposBatch)
+		switch {
+		case errors.Is(err, errEarlyExit):
+			return nil
+		case err != nil:
+			return err
+		}
+
+		if resp.NextPage == 0 {
+			return nil
+		}
+
+		nextPage = resp.NextPage
+	}
+}
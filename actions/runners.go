@@ -0,0 +1,300 @@
+package actions
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+type RunnerFleetFetcher = Fetcher[RunnerFleet]
+
+type SelfHostedRunner struct {
+	Owner string
+	// Repo is empty for organization runners.
+	Repo string
+	// RunnerGroup is empty for repository runners.
+	RunnerGroup string
+
+	Name   string
+	OS     string
+	Status string
+	Busy   bool
+	Labels []string
+}
+
+type RunnerGroup struct {
+	Owner      string
+	Name       string
+	Visibility string
+	Default    bool
+
+	Runners int64
+}
+
+type RunnerFleet struct {
+	Runners []SelfHostedRunner
+	Groups  []RunnerGroup
+
+	// Errors lists everything that could not be fetched during this refresh.
+	// When not empty, the fleet data is partial.
+	Errors []FetchError
+}
+
+func (f *RunnerFleet) merge(other *RunnerFleet) {
+	f.Runners = append(f.Runners, other.Runners...)
+	f.Groups = append(f.Groups, other.Groups...)
+	f.Errors = append(f.Errors, other.Errors...)
+}
+
+func (f *RunnerFleet) addError(fetchErr FetchError) {
+	f.Errors = append(f.Errors, fetchErr)
+}
+
+func NewMultiOrgRunnerFleetFetcher(fetchers map[string]RunnerFleetFetcher, logger *zap.Logger) *MultiOrgFetcher[RunnerFleet] {
+	return &MultiOrgFetcher[RunnerFleet]{
+		fetchers: fetchers,
+		logger:   logger,
+		merge:    (*RunnerFleet).merge,
+		addError: (*RunnerFleet).addError,
+	}
+}
+
+// OrgRunnerFleetFetcher lists the self-hosted runners of an organization, per runner group,
+// as well as the self-hosted runners registered on its active repositories.
+type OrgRunnerFleetFetcher struct {
+	gh     *github.Client
+	logger *zap.Logger
+
+	org     string
+	config  fetcherConfig
+	scanner repoScanner
+}
+
+func NewOrgRunnerFleetFetcher(maxLastPushed time.Duration, org string, gh *github.Client, logger *zap.Logger, opts ...FetcherOpt) *OrgRunnerFleetFetcher {
+	config := newFetcherConfig(opts)
+
+	return &OrgRunnerFleetFetcher{
+		org:    org,
+		gh:     gh,
+		logger: logger,
+		config: config,
+		scanner: repoScanner{
+			org:           org,
+			gh:            gh,
+			maxLastPushed: maxLastPushed,
+			filter:        config.repoFilter,
+			logger:        logger,
+		},
+	}
+}
+
+func (f *OrgRunnerFleetFetcher) Fetch(ctx context.Context) (*RunnerFleet, error) {
+	var (
+		fleetMu sync.Mutex
+		fleet   RunnerFleet
+
+		recordError = func(fetchErr FetchError) {
+			logFetchError(f.logger, fetchErr)
+
+			fleetMu.Lock()
+			fleet.addError(fetchErr)
+			fleetMu.Unlock()
+		}
+
+		repoGroup errgroup.Group
+	)
+
+	f.fetchOrgRunners(ctx, &fleet, recordError)
+
+	repoGroup.SetLimit(f.config.repoConcurrency)
+
+	_, scanErr := f.scanner.scan(ctx, func(repo *github.Repository) {
+		repoGroup.Go(func() error {
+			var runners []SelfHostedRunner
+
+			err := scanAllRunners(
+				func(opts *github.ListOptions) (*github.Runners, *github.Response, error) {
+					return f.gh.Actions.ListRunners(ctx, f.org, repo.GetName(), opts)
+				},
+				func(batch []*github.Runner) {
+					for _, runner := range batch {
+						runners = append(runners, makeSelfHostedRunner(f.org, repo.GetName(), "", runner))
+					}
+				},
+			)
+			if err != nil {
+				recordError(FetchError{
+					Owner: f.org,
+					Repo:  repo.GetName(),
+					Stage: StageListRunners,
+					Err:   err,
+				})
+
+				return nil
+			}
+
+			fleetMu.Lock()
+			fleet.Runners = append(fleet.Runners, runners...)
+			fleetMu.Unlock()
+
+			return nil
+		})
+	})
+
+	_ = repoGroup.Wait()
+
+	if scanErr != nil {
+		return nil, scanErr
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return &fleet, nil
+}
+
+// fetchOrgRunners lists organization runners through their runner group, to know which group they belong to.
+// If runner groups can't be listed, it falls back to listing the organization runners directly.
+// It runs before the repository scan starts, so fleet does not need to be locked.
+func (f *OrgRunnerFleetFetcher) fetchOrgRunners(ctx context.Context, fleet *RunnerFleet, recordError func(FetchError)) {
+	var groups []*github.RunnerGroup
+
+	err := scanAllOrgRunnerGroups(ctx, f.org, f.gh.Actions, func(batch []*github.RunnerGroup) {
+		groups = append(groups, batch...)
+	})
+	if err != nil {
+		recordError(FetchError{
+			Owner: f.org,
+			Stage: StageListRunnerGroups,
+			Err:   err,
+		})
+
+		err = scanAllRunners(
+			func(opts *github.ListOptions) (*github.Runners, *github.Response, error) {
+				return f.gh.Actions.ListOrganizationRunners(ctx, f.org, opts)
+			},
+			func(batch []*github.Runner) {
+				for _, runner := range batch {
+					fleet.Runners = append(fleet.Runners, makeSelfHostedRunner(f.org, "", "", runner))
+				}
+			},
+		)
+		if err != nil {
+			recordError(FetchError{
+				Owner: f.org,
+				Stage: StageListRunners,
+				Err:   err,
+			})
+		}
+
+		return
+	}
+
+	for _, group := range groups {
+		runnerGroup := RunnerGroup{
+			Owner:      f.org,
+			Name:       group.GetName(),
+			Visibility: group.GetVisibility(),
+			Default:    group.GetDefault(),
+		}
+
+		err := scanAllRunners(
+			func(opts *github.ListOptions) (*github.Runners, *github.Response, error) {
+				return f.gh.Actions.ListRunnerGroupRunners(ctx, f.org, group.GetID(), opts)
+			},
+			func(batch []*github.Runner) {
+				for _, runner := range batch {
+					runnerGroup.Runners++
+					fleet.Runners = append(fleet.Runners, makeSelfHostedRunner(f.org, "", group.GetName(), runner))
+				}
+			},
+		)
+		if err != nil {
+			recordError(FetchError{
+				Owner: f.org,
+				Stage: StageListRunners,
+				Err:   err,
+			})
+
+			continue
+		}
+
+		fleet.Groups = append(fleet.Groups, runnerGroup)
+	}
+}
+
+func makeSelfHostedRunner(org, repo, runnerGroup string, ghRunner *github.Runner) SelfHostedRunner {
+	labels := make([]string, 0, len(ghRunner.Labels))
+	for _, label := range ghRunner.Labels {
+		labels = append(labels, label.GetName())
+	}
+
+	return SelfHostedRunner{
+		Owner:       org,
+		Repo:        repo,
+		RunnerGroup: runnerGroup,
+		Name:        ghRunner.GetName(),
+		OS:          ghRunner.GetOS(),
+		Status:      ghRunner.GetStatus(),
+		Busy:        ghRunner.GetBusy(),
+		Labels:      labels,
+	}
+}
+
+func scanAllOrgRunnerGroups(ctx context.Context, org string, actionsClient *github.ActionsService, cb func([]*github.RunnerGroup)) error {
+	var nextPage int
+
+	for {
+		groupsBatch, resp, err := actionsClient.ListOrganizationRunnerGroups(
+			ctx,
+			org,
+			&github.ListOrgRunnerGroupOptions{
+				ListOptions: github.ListOptions{
+					Page:    nextPage,
+					PerPage: 100,
+				},
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		cb(groupsBatch.RunnerGroups)
+
+		if resp.NextPage == 0 {
+			return nil
+		}
+
+		nextPage = resp.NextPage
+	}
+}
+
+// scanAllRunners pages through any of the runner listing endpoints.
+func scanAllRunners(list func(*github.ListOptions) (*github.Runners, *github.Response, error), cb func([]*github.Runner)) error {
+	var nextPage int
+
+	for {
+		runnersBatch, resp, err := list(
+			&github.ListOptions{
+				Page:    nextPage,
+				PerPage: 100,
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		cb(runnersBatch.Runners)
+
+		if resp.NextPage == 0 {
+			return nil
+		}
+
+		nextPage = resp.NextPage
+	}
+}
@@ -0,0 +1,207 @@
+package actions
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+type RunnersCollector struct {
+	runnersDesc      *prometheus.Desc
+	runnerLabelsDesc *prometheus.Desc
+	groupRunnersDesc *prometheus.Desc
+
+	loop *refreshLoop
+
+	fleetFetcherMu sync.RWMutex
+	fleetFetcher   RunnerFleetFetcher
+
+	lastFleetDataMu sync.RWMutex
+	runnerCounts    map[runnerKey]float64
+	labelCounts     map[runnerLabelKey]float64
+	groups          []RunnerGroup
+
+	logger *zap.Logger
+}
+
+func NewRunnersCollector(fleetFetcher RunnerFleetFetcher, logger *zap.Logger, refreshPeriod time.Duration) *RunnersCollector {
+	c := RunnersCollector{
+		logger:       logger,
+		fleetFetcher: fleetFetcher,
+
+		runnersDesc: prometheus.NewDesc(
+			"github_actions_runners",
+			"Last reported total of self-hosted runners, per status, busy state and OS",
+			[]string{"owner", "repo", "runner_group", "status", "busy", "os"},
+			nil,
+		),
+		runnerLabelsDesc: prometheus.NewDesc(
+			"github_actions_runners_by_label",
+			"Last reported total of self-hosted runners having a label, per status and busy state",
+			[]string{"owner", "repo", "runner_group", "label", "status", "busy"},
+			nil,
+		),
+		groupRunnersDesc: prometheus.NewDesc(
+			"github_actions_runner_group_runners",
+			"Last reported total of self-hosted runners in an organization runner group",
+			[]string{"owner", "runner_group", "visibility", "default"},
+			nil,
+		),
+	}
+
+	c.loop = startRefreshLoop(refreshPeriod, c.refresh)
+
+	return &c
+}
+
+func (c *RunnersCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.runnersDesc
+	ch <- c.runnerLabelsDesc
+	ch <- c.groupRunnersDesc
+}
+
+func (c *RunnersCollector) Collect(ch chan<- prometheus.Metric) {
+	c.lastFleetDataMu.RLock()
+	defer c.lastFleetDataMu.RUnlock()
+
+	for key, value := range c.runnerCounts {
+		ch <- prometheus.MustNewConstMetric(
+			c.runnersDesc,
+			prometheus.GaugeValue,
+			value,
+			key.owner,
+			key.repo,
+			key.runnerGroup,
+			key.status,
+			key.busy,
+			key.os,
+		)
+	}
+
+	for key, value := range c.labelCounts {
+		ch <- prometheus.MustNewConstMetric(
+			c.runnerLabelsDesc,
+			prometheus.GaugeValue,
+			value,
+			key.owner,
+			key.repo,
+			key.runnerGroup,
+			key.label,
+			key.status,
+			key.busy,
+		)
+	}
+
+	for _, group := range c.groups {
+		ch <- prometheus.MustNewConstMetric(
+			c.groupRunnersDesc,
+			prometheus.GaugeValue,
+			float64(group.Runners),
+			group.Owner,
+			group.Name,
+			group.Visibility,
+			strconv.FormatBool(group.Default),
+		)
+	}
+}
+
+func (c *RunnersCollector) Close() error {
+	return c.loop.Close()
+}
+
+func (c *RunnersCollector) Ready() <-chan struct{} {
+	return c.loop.Ready()
+}
+
+// SetFetcher replaces the runner fleet fetcher, for instance when the configuration changes. It is used from the next refresh on.
+func (c *RunnersCollector) SetFetcher(fetcher RunnerFleetFetcher) {
+	c.fleetFetcherMu.Lock()
+	defer c.fleetFetcherMu.Unlock()
+
+	c.fleetFetcher = fetcher
+}
+
+func (c *RunnersCollector) fetcher() RunnerFleetFetcher {
+	c.fleetFetcherMu.RLock()
+	defer c.fleetFetcherMu.RUnlock()
+
+	return c.fleetFetcher
+}
+
+func (c *RunnersCollector) refresh(ctx context.Context) {
+	c.logger.Info("Refreshing runners data")
+
+	fleetData, err := c.fetcher().Fetch(ctx)
+	if err != nil {
+		c.logger.Error(
+			"Could not retrieve updated runners data",
+			zap.Error(err),
+		)
+
+		return
+	}
+
+	var (
+		runnerCounts = make(map[runnerKey]float64)
+		labelCounts  = make(map[runnerLabelKey]float64)
+	)
+
+	for _, runner := range fleetData.Runners {
+		busy := strconv.FormatBool(runner.Busy)
+
+		runnerCounts[runnerKey{
+			owner:       runner.Owner,
+			repo:        runner.Repo,
+			runnerGroup: runner.RunnerGroup,
+			status:      runner.Status,
+			busy:        busy,
+			os:          runner.OS,
+		}]++
+
+		for _, label := range runner.Labels {
+			labelCounts[runnerLabelKey{
+				owner:       runner.Owner,
+				repo:        runner.Repo,
+				runnerGroup: runner.RunnerGroup,
+				label:       label,
+				status:      runner.Status,
+				busy:        busy,
+			}]++
+		}
+	}
+
+	c.logger.Info(
+		"Done refreshing runners data",
+		zap.Int("runners", len(fleetData.Runners)),
+		zap.Int("runner_groups", len(fleetData.Groups)),
+		zap.Int("errors", len(fleetData.Errors)),
+	)
+
+	c.lastFleetDataMu.Lock()
+	c.runnerCounts = runnerCounts
+	c.labelCounts = labelCounts
+	c.groups = fleetData.Groups
+	c.lastFleetDataMu.Unlock()
+}
+
+type runnerKey struct {
+	owner       string
+	repo        string
+	runnerGroup string
+	status      string
+	busy        string
+	os          string
+}
+
+type runnerLabelKey struct {
+	owner       string
+	repo        string
+	runnerGroup string
+	label       string
+	status      string
+	busy        string
+}
@@ -0,0 +1,216 @@
+package actions_test
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+	"github.com/jlevesy/workflows-exporter/actions"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+var (
+	getOrgsActionsRunnerGroupsByOrg = mock.EndpointPattern{
+		Pattern: "/orgs/{org}/actions/runner-groups",
+		Method:  "GET",
+	}
+	getOrgsActionsRunnerGroupsRunnersByOrgByRunnerGroupID = mock.EndpointPattern{
+		Pattern: "/orgs/{org}/actions/runner-groups/{runner_group_id}/runners",
+		Method:  "GET",
+	}
+
+	runnerGroups = github.RunnerGroups{
+		TotalCount: 2,
+		RunnerGroups: []*github.RunnerGroup{
+			{ID: ptr(int64(1)), Name: ptr("Default"), Visibility: ptr("all"), Default: ptr(true)},
+			{ID: ptr(int64(2)), Name: ptr("builders"), Visibility: ptr("selected"), Default: ptr(false)},
+		},
+	}
+
+	defaultGroupRunners = github.Runners{
+		TotalCount: 1,
+		Runners: []*github.Runner{
+			{
+				ID:     ptr(int64(1)),
+				Name:   ptr("runner-1"),
+				OS:     ptr("Linux"),
+				Status: ptr("online"),
+				Busy:   ptr(false),
+				Labels: []*github.RunnerLabels{{Name: ptr("self-hosted")}, {Name: ptr("linux")}},
+			},
+		},
+	}
+
+	buildersGroupRunners = github.Runners{
+		TotalCount: 2,
+		Runners: []*github.Runner{
+			{
+				ID:     ptr(int64(2)),
+				Name:   ptr("runner-2"),
+				OS:     ptr("Linux"),
+				Status: ptr("online"),
+				Busy:   ptr(true),
+				Labels: []*github.RunnerLabels{{Name: ptr("self-hosted")}, {Name: ptr("large")}},
+			},
+			{
+				ID:     ptr(int64(3)),
+				Name:   ptr("runner-3"),
+				OS:     ptr("Linux"),
+				Status: ptr("offline"),
+				Busy:   ptr(false),
+				Labels: []*github.RunnerLabels{{Name: ptr("self-hosted")}, {Name: ptr("large")}},
+			},
+		},
+	}
+)
+
+// groupRunnersHandler serves the runners of each group in runnerGroups.
+func groupRunnersHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/orgs/totocorp/actions/runner-groups/1/runners":
+			_, _ = w.Write(mock.MustMarshal(defaultGroupRunners))
+		case "/orgs/totocorp/actions/runner-groups/2/runners":
+			_, _ = w.Write(mock.MustMarshal(buildersGroupRunners))
+		default:
+			_, _ = w.Write(mock.MustMarshal(github.Runners{}))
+		}
+	})
+}
+
+// repoRunnersHandler serves a single runner for repo-B, and nothing for the others.
+func repoRunnersHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/totocorp/repo-B/actions/runners" {
+			_, _ = w.Write(mock.MustMarshal(github.Runners{}))
+			return
+		}
+
+		_, _ = w.Write(mock.MustMarshal(github.Runners{
+			TotalCount: 1,
+			Runners: []*github.Runner{
+				{
+					ID:     ptr(int64(4)),
+					Name:   ptr("repo-runner"),
+					OS:     ptr("Windows"),
+					Status: ptr("online"),
+					Busy:   ptr(false),
+					Labels: []*github.RunnerLabels{{Name: ptr("self-hosted")}},
+				},
+			},
+		}))
+	})
+}
+
+func TestRunnersCollector(t *testing.T) {
+	for _, testCase := range []struct {
+		desc        string
+		mockOptions []mock.MockBackendOption
+		wantMetrics string
+	}{
+		{
+			desc: "lists runners per group",
+			mockOptions: []mock.MockBackendOption{
+				mock.WithRequestMatchPages(getOrgsActionsRunnerGroupsByOrg, runnerGroups),
+				mock.WithRequestMatchHandler(
+					getOrgsActionsRunnerGroupsRunnersByOrgByRunnerGroupID,
+					groupRunnersHandler(),
+				),
+			},
+			wantMetrics: `
+# HELP github_actions_runner_group_runners Last reported total of self-hosted runners in an organization runner group
+# TYPE github_actions_runner_group_runners gauge
+github_actions_runner_group_runners{default="false",owner="totocorp",runner_group="builders",visibility="selected"} 2
+github_actions_runner_group_runners{default="true",owner="totocorp",runner_group="Default",visibility="all"} 1
+# HELP github_actions_runners Last reported total of self-hosted runners, per status, busy state and OS
+# TYPE github_actions_runners gauge
+github_actions_runners{busy="false",os="Linux",owner="totocorp",repo="",runner_group="Default",status="online"} 1
+github_actions_runners{busy="false",os="Linux",owner="totocorp",repo="",runner_group="builders",status="offline"} 1
+github_actions_runners{busy="true",os="Linux",owner="totocorp",repo="",runner_group="builders",status="online"} 1
+github_actions_runners{busy="false",os="Windows",owner="totocorp",repo="repo-B",runner_group="",status="online"} 1
+# HELP github_actions_runners_by_label Last reported total of self-hosted runners having a label, per status and busy state
+# TYPE github_actions_runners_by_label gauge
+github_actions_runners_by_label{busy="false",label="linux",owner="totocorp",repo="",runner_group="Default",status="online"} 1
+github_actions_runners_by_label{busy="false",label="self-hosted",owner="totocorp",repo="",runner_group="Default",status="online"} 1
+github_actions_runners_by_label{busy="false",label="large",owner="totocorp",repo="",runner_group="builders",status="offline"} 1
+github_actions_runners_by_label{busy="false",label="self-hosted",owner="totocorp",repo="",runner_group="builders",status="offline"} 1
+github_actions_runners_by_label{busy="true",label="large",owner="totocorp",repo="",runner_group="builders",status="online"} 1
+github_actions_runners_by_label{busy="true",label="self-hosted",owner="totocorp",repo="",runner_group="builders",status="online"} 1
+github_actions_runners_by_label{busy="false",label="self-hosted",owner="totocorp",repo="repo-B",runner_group="",status="online"} 1
+`,
+		},
+		{
+			desc: "falls back to organization runners without runner groups",
+			mockOptions: []mock.MockBackendOption{
+				mock.WithRequestMatchHandler(
+					getOrgsActionsRunnerGroupsByOrg,
+					failingHandler("/orgs/totocorp/", nil),
+				),
+				mock.WithRequestMatchPages(mock.GetOrgsActionsRunnersByOrg, defaultGroupRunners),
+			},
+			wantMetrics: `
+# HELP github_actions_runners Last reported total of self-hosted runners, per status, busy state and OS
+# TYPE github_actions_runners gauge
+github_actions_runners{busy="false",os="Linux",owner="totocorp",repo="",runner_group="",status="online"} 1
+github_actions_runners{busy="false",os="Windows",owner="totocorp",repo="repo-B",runner_group="",status="online"} 1
+# HELP github_actions_runners_by_label Last reported total of self-hosted runners having a label, per status and busy state
+# TYPE github_actions_runners_by_label gauge
+github_actions_runners_by_label{busy="false",label="linux",owner="totocorp",repo="",runner_group="",status="online"} 1
+github_actions_runners_by_label{busy="false",label="self-hosted",owner="totocorp",repo="",runner_group="",status="online"} 1
+github_actions_runners_by_label{busy="false",label="self-hosted",owner="totocorp",repo="repo-B",runner_group="",status="online"} 1
+`,
+		},
+	} {
+		t.Run(testCase.desc, func(t *testing.T) {
+			var (
+				logger = zaptest.NewLogger(t)
+				gh     = github.NewClient(
+					mock.NewMockedHTTPClient(
+						append(
+							[]mock.MockBackendOption{
+								mock.WithRequestMatchPages(mock.GetOrgsReposByOrg, repos...),
+								mock.WithRequestMatchHandler(
+									mock.GetReposActionsRunnersByOwnerByRepo,
+									repoRunnersHandler(),
+								),
+							},
+							testCase.mockOptions...,
+						)...,
+					),
+				)
+				fetcher = actions.NewMultiOrgRunnerFleetFetcher(
+					map[string]actions.RunnerFleetFetcher{
+						"totocorp": actions.NewOrgRunnerFleetFetcher(
+							24*time.Hour,
+							"totocorp",
+							gh,
+							logger,
+						),
+					},
+					logger,
+				)
+				collector = actions.NewRunnersCollector(fetcher, logger, 10*time.Minute)
+				registry  = prometheus.NewRegistry()
+			)
+
+			defer collector.Close()
+
+			err := registry.Register(collector)
+			require.NoError(t, err)
+
+			<-collector.Ready()
+
+			err = testutil.GatherAndCompare(
+				registry,
+				bytes.NewBufferString(testCase.wantMetrics),
+			)
+			require.NoError(t, err)
+		})
+	}
+}
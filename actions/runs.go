@@ -0,0 +1,357 @@
+package actions
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+type WorkflowRunsFetcher = Fetcher[Runs]
+
+type WorkflowRun struct {
+	Owner      string
+	Repo       string
+	Workflow   string
+	WorkflowID int64
+	ID         int64
+
+	Status     string
+	Conclusion string
+	Event      string
+	Branch     string
+
+	CreatedAt time.Time
+	StartedAt time.Time
+	UpdatedAt time.Time
+}
+
+type WorkflowJob struct {
+	Owner    string
+	Repo     string
+	Workflow string
+	RunID    int64
+	ID       int64
+	Name     string
+
+	Status     string
+	Conclusion string
+
+	// Labels are the runner labels requested by the `runs-on:` key of the job.
+	Labels      []string
+	RunnerName  string
+	RunnerGroup string
+
+	CreatedAt   time.Time
+	StartedAt   time.Time
+	CompletedAt time.Time
+}
+
+// CollectedRepo is a repository whose runs are collected.
+type CollectedRepo struct {
+	Owner string
+	Name  string
+}
+
+type Runs struct {
+	Runs []WorkflowRun
+
+	// Jobs is only populated when the fetcher is configured WithWorkflowJobs.
+	Jobs []WorkflowJob
+
+	// Repos lists the repositories whose runs have been listed.
+	Repos []CollectedRepo
+
+	// Errors lists everything that could not be fetched during this refresh.
+	// When not empty, the runs data is partial.
+	Errors []FetchError
+}
+
+func (r *Runs) merge(other *Runs) {
+	r.Runs = append(r.Runs, other.Runs...)
+	r.Jobs = append(r.Jobs, other.Jobs...)
+	r.Repos = append(r.Repos, other.Repos...)
+	r.Errors = append(r.Errors, other.Errors...)
+}
+
+func (r *Runs) addError(fetchErr FetchError) {
+	r.Errors = append(r.Errors, fetchErr)
+}
+
+func NewMultiOrgRunsFetcher(fetchers map[string]WorkflowRunsFetcher, logger *zap.Logger) *MultiOrgFetcher[Runs] {
+	return &MultiOrgFetcher[Runs]{
+		fetchers: fetchers,
+		logger:   logger,
+		merge:    (*Runs).merge,
+		addError: (*Runs).addError,
+	}
+}
+
+// OrgRunsFetcher lists the workflow runs created during the lookback window, for all active repositories of an organization.
+type OrgRunsFetcher struct {
+	gh     *github.Client
+	logger *zap.Logger
+
+	org      string
+	lookback time.Duration
+	config   fetcherConfig
+	scanner  repoScanner
+	nowFunc  func() time.Time
+}
+
+func NewOrgRunsFetcher(lookback, maxLastPushed time.Duration, org string, gh *github.Client, logger *zap.Logger, opts ...FetcherOpt) *OrgRunsFetcher {
+	config := newFetcherConfig(opts)
+
+	return &OrgRunsFetcher{
+		org:      org,
+		gh:       gh,
+		logger:   logger,
+		lookback: lookback,
+		config:   config,
+		nowFunc:  time.Now,
+		scanner: repoScanner{
+			org:           org,
+			gh:            gh,
+			maxLastPushed: maxLastPushed,
+			filter:        config.repoFilter,
+			logger:        logger,
+		},
+	}
+}
+
+func (f *OrgRunsFetcher) Fetch(ctx context.Context) (*Runs, error) {
+	var (
+		runsMu sync.Mutex
+		runs   Runs
+
+		createdSince = f.nowFunc().Add(-f.lookback).UTC()
+
+		recordError = func(fetchErr FetchError) {
+			logFetchError(f.logger, fetchErr)
+
+			runsMu.Lock()
+			runs.addError(fetchErr)
+			runsMu.Unlock()
+		}
+
+		// See OrgUsageFetcher.Fetch for why two groups are needed.
+		repoGroup     errgroup.Group
+		workflowGroup errgroup.Group
+	)
+
+	repoGroup.SetLimit(f.config.repoConcurrency)
+	workflowGroup.SetLimit(f.config.workflowConcurrency)
+
+	_, scanErr := f.scanner.scan(ctx, func(repo *github.Repository) {
+		runsMu.Lock()
+		runs.Repos = append(runs.Repos, CollectedRepo{Owner: f.org, Name: repo.GetName()})
+		runsMu.Unlock()
+
+		repoGroup.Go(func() error {
+			err := scanAllRepoWorkflows(
+				ctx,
+				f.org,
+				repo.GetName(),
+				f.gh.Actions,
+				func(workflows *github.Workflows) {
+					for _, workflow := range workflows.Workflows {
+						workflow := workflow
+
+						workflowGroup.Go(func() error {
+							var workflowRuns []WorkflowRun
+
+							err := scanAllWorkflowRuns(
+								ctx,
+								f.org,
+								repo.GetName(),
+								workflow.GetID(),
+								createdSince,
+								f.gh.Actions,
+								func(ghRuns []*github.WorkflowRun) {
+									for _, ghRun := range ghRuns {
+										workflowRuns = append(
+											workflowRuns,
+											makeWorkflowRun(f.org, repo.GetName(), workflow, ghRun),
+										)
+									}
+								},
+							)
+							if err != nil {
+								recordError(FetchError{
+									Owner:    f.org,
+									Repo:     repo.GetName(),
+									Workflow: workflow.GetName(),
+									Stage:    StageListRuns,
+									Err:      err,
+								})
+							}
+
+							var workflowJobs []WorkflowJob
+
+							if f.config.collectJobs {
+								for _, run := range workflowRuns {
+									err := scanAllWorkflowJobs(
+										ctx,
+										f.org,
+										repo.GetName(),
+										run.ID,
+										f.gh.Actions,
+										func(ghJobs []*github.WorkflowJob) {
+											for _, ghJob := range ghJobs {
+												workflowJobs = append(
+													workflowJobs,
+													makeWorkflowJob(f.org, repo.GetName(), workflow.GetName(), ghJob),
+												)
+											}
+										},
+									)
+									if err != nil {
+										recordError(FetchError{
+											Owner:    f.org,
+											Repo:     repo.GetName(),
+											Workflow: workflow.GetName(),
+											Stage:    StageListJobs,
+											Err:      err,
+										})
+									}
+								}
+							}
+
+							// Keep the runs of the pages that could be retrieved.
+							runsMu.Lock()
+							runs.Runs = append(runs.Runs, workflowRuns...)
+							runs.Jobs = append(runs.Jobs, workflowJobs...)
+							runsMu.Unlock()
+
+							return nil
+						})
+					}
+				},
+			)
+			if err != nil {
+				recordError(FetchError{
+					Owner: f.org,
+					Repo:  repo.GetName(),
+					Stage: StageListWorkflows,
+					Err:   err,
+				})
+			}
+
+			return nil
+		})
+	})
+
+	_ = repoGroup.Wait()
+	_ = workflowGroup.Wait()
+
+	if scanErr != nil {
+		return nil, scanErr
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return &runs, nil
+}
+
+func makeWorkflowRun(org, repo string, workflow *github.Workflow, ghRun *github.WorkflowRun) WorkflowRun {
+	return WorkflowRun{
+		Owner:      org,
+		Repo:       repo,
+		Workflow:   workflow.GetName(),
+		WorkflowID: workflow.GetID(),
+		ID:         ghRun.GetID(),
+		Status:     ghRun.GetStatus(),
+		Conclusion: ghRun.GetConclusion(),
+		Event:      ghRun.GetEvent(),
+		Branch:     ghRun.GetHeadBranch(),
+		CreatedAt:  ghRun.GetCreatedAt().Time,
+		StartedAt:  ghRun.GetRunStartedAt().Time,
+		UpdatedAt:  ghRun.GetUpdatedAt().Time,
+	}
+}
+
+func makeWorkflowJob(org, repo, workflow string, ghJob *github.WorkflowJob) WorkflowJob {
+	return WorkflowJob{
+		Owner:       org,
+		Repo:        repo,
+		Workflow:    workflow,
+		RunID:       ghJob.GetRunID(),
+		ID:          ghJob.GetID(),
+		Name:        ghJob.GetName(),
+		Status:      ghJob.GetStatus(),
+		Conclusion:  ghJob.GetConclusion(),
+		Labels:      ghJob.Labels,
+		RunnerName:  ghJob.GetRunnerName(),
+		RunnerGroup: ghJob.GetRunnerGroupName(),
+		CreatedAt:   ghJob.GetCreatedAt().Time,
+		StartedAt:   ghJob.GetStartedAt().Time,
+		CompletedAt: ghJob.GetCompletedAt().Time,
+	}
+}
+
+func scanAllWorkflowRuns(ctx context.Context, org, repo string, workflowID int64, createdSince time.Time, workflowClient *github.ActionsService, cb func([]*github.WorkflowRun)) error {
+	var nextPage int
+
+	for {
+		runsBatch, resp, err := workflowClient.ListWorkflowRunsByID(
+			ctx,
+			org,
+			repo,
+			workflowID,
+			&github.ListWorkflowRunsOptions{
+				Created: ">=" + createdSince.Format(time.RFC3339),
+				ListOptions: github.ListOptions{
+					Page:    nextPage,
+					PerPage: 100,
+				},
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		cb(runsBatch.WorkflowRuns)
+
+		if resp.NextPage == 0 {
+			return nil
+		}
+
+		nextPage = resp.NextPage
+	}
+}
+
+func scanAllWorkflowJobs(ctx context.Context, org, repo string, runID int64, workflowClient *github.ActionsService, cb func([]*github.WorkflowJob)) error {
+	var nextPage int
+
+	for {
+		jobsBatch, resp, err := workflowClient.ListWorkflowJobs(
+			ctx,
+			org,
+			repo,
+			runID,
+			&github.ListWorkflowJobsOptions{
+				Filter: "latest",
+				ListOptions: github.ListOptions{
+					Page:    nextPage,
+					PerPage: 100,
+				},
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		cb(jobsBatch.Jobs)
+
+		if resp.NextPage == 0 {
+			return nil
+		}
+
+		nextPage = resp.NextPage
+	}
+}
@@ -0,0 +1,519 @@
+package actions
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+type RunsCollectorOpt func(c *RunsCollector)
+
+// WithDurationBuckets sets the upper bounds, in seconds, of the queue and execution time histograms.
+func WithDurationBuckets(buckets []float64) RunsCollectorOpt {
+	return func(c *RunsCollector) {
+		c.durationBuckets = buckets
+	}
+}
+
+// WithJobs tells the collector that its fetcher lists the jobs of the runs, for the jobs reported by webhook events to be accounted for.
+func WithJobs() RunsCollectorOpt {
+	return func(c *RunsCollector) {
+		c.collectJobs = true
+	}
+}
+
+// WithRunsLookback drops the runs and jobs reported by webhook events which were created before the lookback window,
+// like the fetcher does for the polled ones.
+func WithRunsLookback(lookback time.Duration) RunsCollectorOpt {
+	return func(c *RunsCollector) {
+		c.lookback = lookback
+	}
+}
+
+func WithRunsNowFunc(fn func() time.Time) RunsCollectorOpt {
+	return func(c *RunsCollector) {
+		c.nowFunc = fn
+	}
+}
+
+type RunsCollector struct {
+	runsDesc             *prometheus.Desc
+	runQueueTimeDesc     *prometheus.Desc
+	runExecutionTimeDesc *prometheus.Desc
+	jobQueueTimeDesc     *prometheus.Desc
+	jobExecutionTimeDesc *prometheus.Desc
+	jobsDesc             *prometheus.Desc
+	jobDurationDesc      *prometheus.Desc
+
+	loop *refreshLoop
+
+	runsFetcherMu sync.RWMutex
+	runsFetcher   WorkflowRunsFetcher
+
+	durationBuckets []float64
+	collectJobs     bool
+	lookback        time.Duration
+	nowFunc         func() time.Time
+
+	// Runs and jobs are kept by ID, so that webhook events update them in place until the next refresh replaces them.
+	lastRunsDataMu sync.Mutex
+	repos          map[string]CollectedRepo
+	runs           map[int64]WorkflowRun
+	jobs           map[int64]WorkflowJob
+	aggregates     *runsAggregates
+
+	logger *zap.Logger
+}
+
+func NewRunsCollector(runsFetcher WorkflowRunsFetcher, logger *zap.Logger, refreshPeriod time.Duration, opts ...RunsCollectorOpt) *RunsCollector {
+	c := RunsCollector{
+		logger:          logger,
+		runsFetcher:     runsFetcher,
+		durationBuckets: DefaultDurationBuckets,
+		nowFunc:         time.Now,
+
+		runsDesc: prometheus.NewDesc(
+			"github_actions_workflow_runs",
+			"Total of workflow runs created during the lookback window, per status and conclusion",
+			[]string{"owner", "repo", "workflow", "status", "conclusion", "event", "branch"},
+			nil,
+		),
+		runQueueTimeDesc: prometheus.NewDesc(
+			"github_actions_workflow_run_queue_time_seconds",
+			"Time between the creation and the start of the workflow runs created during the lookback window",
+			[]string{"owner", "repo", "workflow"},
+			nil,
+		),
+		runExecutionTimeDesc: prometheus.NewDesc(
+			"github_actions_workflow_run_execution_time_seconds",
+			"Time between the start and the completion of the workflow runs created during the lookback window",
+			[]string{"owner", "repo", "workflow"},
+			nil,
+		),
+		jobQueueTimeDesc: prometheus.NewDesc(
+			"github_actions_workflow_job_queue_time_seconds",
+			"Time spent by the jobs of the workflow runs created during the lookback window waiting for a runner, per runner labels",
+			[]string{"owner", "repo", "workflow", "runner_labels"},
+			nil,
+		),
+		jobExecutionTimeDesc: prometheus.NewDesc(
+			"github_actions_workflow_job_execution_time_seconds",
+			"Time between the start and the completion of the jobs of the workflow runs created during the lookback window, per runner labels",
+			[]string{"owner", "repo", "workflow", "runner_labels"},
+			nil,
+		),
+		jobsDesc: prometheus.NewDesc(
+			"github_actions_workflow_jobs",
+			"Total of jobs of the workflow runs created during the lookback window, per job, status, conclusion and runner",
+			[]string{"owner", "repo", "workflow", "job", "status", "conclusion", "runner_labels", "runner_group"},
+			nil,
+		),
+		jobDurationDesc: prometheus.NewDesc(
+			"github_actions_workflow_job_duration_seconds",
+			"Total execution time of the completed jobs of the workflow runs created during the lookback window, per job and runner",
+			[]string{"owner", "repo", "workflow", "job", "runner_labels", "runner_group"},
+			nil,
+		),
+	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	c.loop = startRefreshLoop(refreshPeriod, c.refresh)
+
+	return &c
+}
+
+func (c *RunsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.runsDesc
+	ch <- c.runQueueTimeDesc
+	ch <- c.runExecutionTimeDesc
+	ch <- c.jobQueueTimeDesc
+	ch <- c.jobExecutionTimeDesc
+	ch <- c.jobsDesc
+	ch <- c.jobDurationDesc
+}
+
+func (c *RunsCollector) Collect(ch chan<- prometheus.Metric) {
+	aggregates := c.currentAggregates()
+
+	// Runs are counted over a sliding window, this is not a monotonic counter.
+	for key, value := range aggregates.runCounts {
+		ch <- prometheus.MustNewConstMetric(
+			c.runsDesc,
+			prometheus.GaugeValue,
+			value,
+			key.owner,
+			key.repo,
+			key.workflow,
+			key.status,
+			key.conclusion,
+			key.event,
+			key.branch,
+		)
+	}
+
+	for key, value := range aggregates.jobCounts {
+		ch <- prometheus.MustNewConstMetric(
+			c.jobsDesc,
+			prometheus.GaugeValue,
+			value,
+			key.owner,
+			key.repo,
+			key.workflow,
+			key.job,
+			key.status,
+			key.conclusion,
+			key.runnerLabels,
+			key.runnerGroup,
+		)
+	}
+
+	for key, value := range aggregates.jobDurations {
+		ch <- prometheus.MustNewConstMetric(
+			c.jobDurationDesc,
+			prometheus.GaugeValue,
+			value,
+			key.owner,
+			key.repo,
+			key.workflow,
+			key.job,
+			key.runnerLabels,
+			key.runnerGroup,
+		)
+	}
+
+	collectHistograms(ch, c.runQueueTimeDesc, aggregates.runQueueTime, false)
+	collectHistograms(ch, c.runExecutionTimeDesc, aggregates.runExecutionTime, false)
+	collectHistograms(ch, c.jobQueueTimeDesc, aggregates.jobQueueTime, true)
+	collectHistograms(ch, c.jobExecutionTimeDesc, aggregates.jobExecutionTime, true)
+}
+
+func collectHistograms(ch chan<- prometheus.Metric, desc *prometheus.Desc, set histogramSet, withRunnerLabels bool) {
+	for key, h := range set.histograms {
+		labels := []string{key.owner, key.repo, key.workflow}
+		if withRunnerLabels {
+			labels = append(labels, key.runnerLabels)
+		}
+
+		ch <- prometheus.MustNewConstHistogram(desc, h.count, h.sum, h.buckets, labels...)
+	}
+}
+
+func (c *RunsCollector) Close() error {
+	return c.loop.Close()
+}
+
+func (c *RunsCollector) Ready() <-chan struct{} {
+	return c.loop.Ready()
+}
+
+// SetFetcher replaces the runs fetcher, for instance when the configuration changes. It is used from the next refresh on.
+func (c *RunsCollector) SetFetcher(fetcher WorkflowRunsFetcher) {
+	c.runsFetcherMu.Lock()
+	defer c.runsFetcherMu.Unlock()
+
+	c.runsFetcher = fetcher
+}
+
+func (c *RunsCollector) fetcher() WorkflowRunsFetcher {
+	c.runsFetcherMu.RLock()
+	defer c.runsFetcherMu.RUnlock()
+
+	return c.runsFetcher
+}
+
+func (c *RunsCollector) refresh(ctx context.Context) {
+	c.logger.Info("Refreshing runs data")
+
+	runsData, err := c.fetcher().Fetch(ctx)
+	if err != nil {
+		c.logger.Error(
+			"Could not retrieve updated runs data",
+			zap.Error(err),
+		)
+
+		return
+	}
+
+	var (
+		repos = make(map[string]CollectedRepo, len(runsData.Repos))
+		runs  = make(map[int64]WorkflowRun, len(runsData.Runs))
+		jobs  = make(map[int64]WorkflowJob, len(runsData.Jobs))
+	)
+
+	for _, repo := range runsData.Repos {
+		repos[collectedRepoKey(repo.Owner, repo.Name)] = repo
+	}
+
+	for _, run := range runsData.Runs {
+		runs[run.ID] = run
+	}
+
+	for _, job := range runsData.Jobs {
+		jobs[job.ID] = job
+	}
+
+	c.logger.Info(
+		"Done refreshing runs data",
+		zap.Int("runs", len(runsData.Runs)),
+		zap.Int("jobs", len(runsData.Jobs)),
+		zap.Int("errors", len(runsData.Errors)),
+	)
+
+	c.lastRunsDataMu.Lock()
+	defer c.lastRunsDataMu.Unlock()
+
+	// Keep the updates received from webhook events while refreshing.
+	for id, run := range runs {
+		if current, ok := c.runs[id]; ok && current.UpdatedAt.After(run.UpdatedAt) {
+			runs[id] = current
+		}
+	}
+
+	for id, job := range jobs {
+		if current, ok := c.jobs[id]; ok && jobProgress(current) > jobProgress(job) {
+			jobs[id] = current
+		}
+	}
+
+	c.repos = repos
+	c.runs = runs
+	c.jobs = jobs
+	c.aggregates = nil
+}
+
+// ObserveRun records a run reported by a webhook event. It is ignored unless its repository has been collected
+// by the last refresh and it has been created during the lookback window, and is replaced by the polled data on the next refresh.
+func (c *RunsCollector) ObserveRun(run WorkflowRun) bool {
+	if !c.inLookback(run.CreatedAt) {
+		return false
+	}
+
+	c.lastRunsDataMu.Lock()
+	defer c.lastRunsDataMu.Unlock()
+
+	repo, ok := c.repos[collectedRepoKey(run.Owner, run.Repo)]
+	if !ok {
+		return false
+	}
+
+	run.Owner, run.Repo = repo.Owner, repo.Name
+
+	// Redelivered or out of order events must not bring back an older state.
+	if current, ok := c.runs[run.ID]; ok && current.UpdatedAt.After(run.UpdatedAt) {
+		return true
+	}
+
+	c.runs[run.ID] = run
+	c.aggregates = nil
+
+	return true
+}
+
+// ObserveJob records a job reported by a webhook event, see ObserveRun. Jobs are ignored unless the collector is configured WithJobs.
+func (c *RunsCollector) ObserveJob(job WorkflowJob) bool {
+	if !c.collectJobs {
+		return false
+	}
+
+	c.lastRunsDataMu.Lock()
+	defer c.lastRunsDataMu.Unlock()
+
+	repo, ok := c.repos[collectedRepoKey(job.Owner, job.Repo)]
+	if !ok {
+		return false
+	}
+
+	// A job can be created well after its run, when it is retried for instance: the window applies to the run.
+	createdAt := job.CreatedAt
+	if run, ok := c.runs[job.RunID]; ok {
+		createdAt = run.CreatedAt
+	}
+
+	if !c.inLookback(createdAt) {
+		return false
+	}
+
+	job.Owner, job.Repo = repo.Owner, repo.Name
+
+	if current, ok := c.jobs[job.ID]; ok && jobProgress(current) > jobProgress(job) {
+		return true
+	}
+
+	c.jobs[job.ID] = job
+	c.aggregates = nil
+
+	return true
+}
+
+// inLookback tells whether something created at the given time belongs to the lookback window, if any.
+func (c *RunsCollector) inLookback(createdAt time.Time) bool {
+	return c.lookback <= 0 || !createdAt.Before(c.nowFunc().Add(-c.lookback))
+}
+
+// currentAggregates computes the metrics out of the runs and jobs, only when they changed since the last call.
+func (c *RunsCollector) currentAggregates() *runsAggregates {
+	c.lastRunsDataMu.Lock()
+	defer c.lastRunsDataMu.Unlock()
+
+	if c.aggregates == nil {
+		c.aggregates = aggregateRuns(c.runs, c.jobs, c.durationBuckets)
+	}
+
+	return c.aggregates
+}
+
+// collectedRepoKey matches the repositories of webhook events regardless of the case used in the configuration.
+func collectedRepoKey(owner, repo string) string {
+	return strings.ToLower(owner + "/" + repo)
+}
+
+// jobProgress orders the statuses of a job, a job can not go back to a previous status.
+func jobProgress(job WorkflowJob) int {
+	switch job.Status {
+	case "completed":
+		return 2
+	case "in_progress":
+		return 1
+	default:
+		return 0
+	}
+}
+
+type runsAggregates struct {
+	runCounts        map[runKey]float64
+	runQueueTime     histogramSet
+	runExecutionTime histogramSet
+	jobQueueTime     histogramSet
+	jobExecutionTime histogramSet
+	jobCounts        map[jobKey]float64
+	jobDurations     map[jobDurationKey]float64
+}
+
+func aggregateRuns(runs map[int64]WorkflowRun, jobs map[int64]WorkflowJob, durationBuckets []float64) *runsAggregates {
+	var (
+		runCounts        = make(map[runKey]float64)
+		runQueueTime     = newHistogramSet(durationBuckets)
+		runExecutionTime = newHistogramSet(durationBuckets)
+		jobQueueTime     = newHistogramSet(durationBuckets)
+		jobExecutionTime = newHistogramSet(durationBuckets)
+		jobCounts        = make(map[jobKey]float64)
+		jobDurations     = make(map[jobDurationKey]float64)
+	)
+
+	for _, run := range runs {
+		runCounts[runKey{
+			owner:      run.Owner,
+			repo:       run.Repo,
+			workflow:   run.Workflow,
+			status:     run.Status,
+			conclusion: run.Conclusion,
+			event:      run.Event,
+			branch:     run.Branch,
+		}]++
+
+		key := histogramKey{owner: run.Owner, repo: run.Repo, workflow: run.Workflow}
+
+		if run.StartedAt.IsZero() {
+			continue
+		}
+
+		runQueueTime.observe(key, run.StartedAt.Sub(run.CreatedAt).Seconds())
+
+		// The API does not expose a completion time for runs, the last update
+		// of a completed run is the closest approximation.
+		if run.Status == "completed" {
+			runExecutionTime.observe(key, run.UpdatedAt.Sub(run.StartedAt).Seconds())
+		}
+	}
+
+	for _, job := range jobs {
+		runnerLabels := joinRunnerLabels(job.Labels)
+
+		jobCounts[jobKey{
+			owner:        job.Owner,
+			repo:         job.Repo,
+			workflow:     job.Workflow,
+			job:          job.Name,
+			status:       job.Status,
+			conclusion:   job.Conclusion,
+			runnerLabels: runnerLabels,
+			runnerGroup:  job.RunnerGroup,
+		}]++
+
+		if job.StartedAt.IsZero() {
+			continue
+		}
+
+		key := histogramKey{
+			owner:        job.Owner,
+			repo:         job.Repo,
+			workflow:     job.Workflow,
+			runnerLabels: runnerLabels,
+		}
+
+		jobQueueTime.observe(key, job.StartedAt.Sub(job.CreatedAt).Seconds())
+
+		if job.Status != "completed" || job.CompletedAt.IsZero() {
+			continue
+		}
+
+		duration := job.CompletedAt.Sub(job.StartedAt).Seconds()
+
+		jobExecutionTime.observe(key, duration)
+		jobDurations[jobDurationKey{
+			owner:        job.Owner,
+			repo:         job.Repo,
+			workflow:     job.Workflow,
+			job:          job.Name,
+			runnerLabels: runnerLabels,
+			runnerGroup:  job.RunnerGroup,
+		}] += duration
+	}
+
+	return &runsAggregates{
+		runCounts:        runCounts,
+		runQueueTime:     runQueueTime,
+		runExecutionTime: runExecutionTime,
+		jobQueueTime:     jobQueueTime,
+		jobExecutionTime: jobExecutionTime,
+		jobCounts:        jobCounts,
+		jobDurations:     jobDurations,
+	}
+}
+
+type runKey struct {
+	owner      string
+	repo       string
+	workflow   string
+	status     string
+	conclusion string
+	event      string
+	branch     string
+}
+
+type jobKey struct {
+	owner        string
+	repo         string
+	workflow     string
+	job          string
+	status       string
+	conclusion   string
+	runnerLabels string
+	runnerGroup  string
+}
+
+type jobDurationKey struct {
+	owner        string
+	repo         string
+	workflow     string
+	job          string
+	runnerLabels string
+	runnerGroup  string
+}
@@ -0,0 +1,326 @@
+package actions_test
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+	"github.com/jlevesy/workflows-exporter/actions"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+var workflowRuns = github.WorkflowRuns{
+	WorkflowRuns: []*github.WorkflowRun{
+		{
+			ID:           ptr(int64(100)),
+			Status:       ptr("completed"),
+			Conclusion:   ptr("success"),
+			Event:        ptr("push"),
+			HeadBranch:   ptr("main"),
+			CreatedAt:    &github.Timestamp{Time: now.Add(-10 * time.Minute)},
+			RunStartedAt: &github.Timestamp{Time: now.Add(-9 * time.Minute)},
+			UpdatedAt:    &github.Timestamp{Time: now.Add(-5 * time.Minute)},
+		},
+		{
+			ID:           ptr(int64(101)),
+			Status:       ptr("completed"),
+			Conclusion:   ptr("success"),
+			Event:        ptr("push"),
+			HeadBranch:   ptr("main"),
+			CreatedAt:    &github.Timestamp{Time: now.Add(-20 * time.Minute)},
+			RunStartedAt: &github.Timestamp{Time: now.Add(-19 * time.Minute)},
+			UpdatedAt:    &github.Timestamp{Time: now.Add(-15 * time.Minute)},
+		},
+		{
+			ID:           ptr(int64(102)),
+			Status:       ptr("completed"),
+			Conclusion:   ptr("failure"),
+			Event:        ptr("pull_request"),
+			HeadBranch:   ptr("feature"),
+			CreatedAt:    &github.Timestamp{Time: now.Add(-30 * time.Minute)},
+			RunStartedAt: &github.Timestamp{Time: now.Add(-28 * time.Minute)},
+			UpdatedAt:    &github.Timestamp{Time: now.Add(-20 * time.Minute)},
+		},
+		{
+			ID:           ptr(int64(103)),
+			Status:       ptr("in_progress"),
+			Event:        ptr("push"),
+			HeadBranch:   ptr("main"),
+			CreatedAt:    &github.Timestamp{Time: now.Add(-2 * time.Minute)},
+			RunStartedAt: &github.Timestamp{Time: now.Add(-1 * time.Minute)},
+			UpdatedAt:    &github.Timestamp{Time: now.Add(-1 * time.Minute)},
+		},
+	},
+}
+
+var workflowJobs = github.Jobs{
+	Jobs: []*github.WorkflowJob{
+		{
+			ID:          ptr(int64(1000)),
+			RunID:       ptr(int64(100)),
+			Name:        ptr("lint"),
+			Status:      ptr("completed"),
+			Conclusion:  ptr("success"),
+			Labels:      []string{"ubuntu-latest"},
+			CreatedAt:   &github.Timestamp{Time: now.Add(-10 * time.Minute)},
+			StartedAt:   &github.Timestamp{Time: now.Add(-570 * time.Second)},
+			CompletedAt: &github.Timestamp{Time: now.Add(-8 * time.Minute)},
+		},
+		{
+			ID:              ptr(int64(1001)),
+			RunID:           ptr(int64(100)),
+			Name:            ptr("build"),
+			Status:          ptr("completed"),
+			Conclusion:      ptr("success"),
+			Labels:          []string{"self-hosted", "linux"},
+			RunnerName:      ptr("runner-1"),
+			RunnerGroupName: ptr("builders"),
+			CreatedAt:       &github.Timestamp{Time: now.Add(-10 * time.Minute)},
+			StartedAt:       &github.Timestamp{Time: now.Add(-8 * time.Minute)},
+			CompletedAt:     &github.Timestamp{Time: now.Add(-5 * time.Minute)},
+		},
+	},
+}
+
+// jobsHandler serves workflowJobs for the run 100, and nothing for the others.
+func jobsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/totocorp/repo-A/actions/runs/100/jobs" {
+			_, _ = w.Write(mock.MustMarshal(github.Jobs{}))
+			return
+		}
+
+		_, _ = w.Write(mock.MustMarshal(workflowJobs))
+	})
+}
+
+// runsHandler serves workflowRuns for the build workflow of repo-A, and nothing for the others.
+func runsHandler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(
+			t,
+			strings.HasPrefix(r.URL.Query().Get("created"), ">="),
+			"runs must be filtered by creation date",
+		)
+
+		if r.URL.Path != "/repos/totocorp/repo-A/actions/workflows/1/runs" {
+			_, _ = w.Write(mock.MustMarshal(github.WorkflowRuns{}))
+			return
+		}
+
+		_, _ = w.Write(mock.MustMarshal(workflowRuns))
+	})
+}
+
+func TestRunsCollector(t *testing.T) {
+	var (
+		logger = zaptest.NewLogger(t)
+		gh     = github.NewClient(
+			mock.NewMockedHTTPClient(
+				mock.WithRequestMatchPages(mock.GetOrgsReposByOrg, repos...),
+				mock.WithRequestMatchPages(mock.GetReposActionsWorkflowsByOwnerByRepo, workflows...),
+				mock.WithRequestMatchHandler(
+					mock.GetReposActionsWorkflowsRunsByOwnerByRepoByWorkflowId,
+					runsHandler(t),
+				),
+			),
+		)
+		fetcher = actions.NewMultiOrgRunsFetcher(
+			map[string]actions.WorkflowRunsFetcher{
+				"totocorp": actions.NewOrgRunsFetcher(
+					time.Hour,
+					24*time.Hour,
+					"totocorp",
+					gh,
+					logger,
+				),
+			},
+			logger,
+		)
+		collector = actions.NewRunsCollector(fetcher, logger, 10*time.Minute)
+		registry  = prometheus.NewRegistry()
+	)
+
+	defer collector.Close()
+
+	err := registry.Register(collector)
+	require.NoError(t, err)
+
+	<-collector.Ready()
+
+	err = testutil.GatherAndCompare(
+		registry,
+		bytes.NewBufferString(`
+# HELP github_actions_workflow_runs Total of workflow runs created during the lookback window, per status and conclusion
+# TYPE github_actions_workflow_runs gauge
+github_actions_workflow_runs{branch="feature",conclusion="failure",event="pull_request",owner="totocorp",repo="repo-A",status="completed",workflow="build"} 1
+github_actions_workflow_runs{branch="main",conclusion="",event="push",owner="totocorp",repo="repo-A",status="in_progress",workflow="build"} 1
+github_actions_workflow_runs{branch="main",conclusion="success",event="push",owner="totocorp",repo="repo-A",status="completed",workflow="build"} 2
+`),
+		"github_actions_workflow_runs",
+	)
+	require.NoError(t, err)
+}
+
+func TestRunsCollector_Durations(t *testing.T) {
+	var (
+		logger = zaptest.NewLogger(t)
+		gh     = github.NewClient(
+			mock.NewMockedHTTPClient(
+				mock.WithRequestMatchPages(mock.GetOrgsReposByOrg, repos...),
+				mock.WithRequestMatchPages(mock.GetReposActionsWorkflowsByOwnerByRepo, workflows...),
+				mock.WithRequestMatchHandler(
+					mock.GetReposActionsWorkflowsRunsByOwnerByRepoByWorkflowId,
+					runsHandler(t),
+				),
+				mock.WithRequestMatchHandler(
+					mock.GetReposActionsRunsJobsByOwnerByRepoByRunId,
+					jobsHandler(),
+				),
+			),
+		)
+		fetcher = actions.NewMultiOrgRunsFetcher(
+			map[string]actions.WorkflowRunsFetcher{
+				"totocorp": actions.NewOrgRunsFetcher(
+					time.Hour,
+					24*time.Hour,
+					"totocorp",
+					gh,
+					logger,
+					actions.WithWorkflowJobs(),
+				),
+			},
+			logger,
+		)
+		collector = actions.NewRunsCollector(
+			fetcher,
+			logger,
+			10*time.Minute,
+			actions.WithDurationBuckets([]float64{60, 300}),
+		)
+		registry = prometheus.NewRegistry()
+	)
+
+	defer collector.Close()
+
+	err := registry.Register(collector)
+	require.NoError(t, err)
+
+	<-collector.Ready()
+
+	err = testutil.GatherAndCompare(
+		registry,
+		bytes.NewBufferString(`
+# HELP github_actions_workflow_job_execution_time_seconds Time between the start and the completion of the jobs of the workflow runs created during the lookback window, per runner labels
+# TYPE github_actions_workflow_job_execution_time_seconds histogram
+github_actions_workflow_job_execution_time_seconds_bucket{owner="totocorp",repo="repo-A",runner_labels="linux,self-hosted",workflow="build",le="60"} 0
+github_actions_workflow_job_execution_time_seconds_bucket{owner="totocorp",repo="repo-A",runner_labels="linux,self-hosted",workflow="build",le="300"} 1
+github_actions_workflow_job_execution_time_seconds_bucket{owner="totocorp",repo="repo-A",runner_labels="linux,self-hosted",workflow="build",le="+Inf"} 1
+github_actions_workflow_job_execution_time_seconds_sum{owner="totocorp",repo="repo-A",runner_labels="linux,self-hosted",workflow="build"} 180
+github_actions_workflow_job_execution_time_seconds_count{owner="totocorp",repo="repo-A",runner_labels="linux,self-hosted",workflow="build"} 1
+github_actions_workflow_job_execution_time_seconds_bucket{owner="totocorp",repo="repo-A",runner_labels="ubuntu-latest",workflow="build",le="60"} 0
+github_actions_workflow_job_execution_time_seconds_bucket{owner="totocorp",repo="repo-A",runner_labels="ubuntu-latest",workflow="build",le="300"} 1
+github_actions_workflow_job_execution_time_seconds_bucket{owner="totocorp",repo="repo-A",runner_labels="ubuntu-latest",workflow="build",le="+Inf"} 1
+github_actions_workflow_job_execution_time_seconds_sum{owner="totocorp",repo="repo-A",runner_labels="ubuntu-latest",workflow="build"} 90
+github_actions_workflow_job_execution_time_seconds_count{owner="totocorp",repo="repo-A",runner_labels="ubuntu-latest",workflow="build"} 1
+# HELP github_actions_workflow_job_queue_time_seconds Time spent by the jobs of the workflow runs created during the lookback window waiting for a runner, per runner labels
+# TYPE github_actions_workflow_job_queue_time_seconds histogram
+github_actions_workflow_job_queue_time_seconds_bucket{owner="totocorp",repo="repo-A",runner_labels="linux,self-hosted",workflow="build",le="60"} 0
+github_actions_workflow_job_queue_time_seconds_bucket{owner="totocorp",repo="repo-A",runner_labels="linux,self-hosted",workflow="build",le="300"} 1
+github_actions_workflow_job_queue_time_seconds_bucket{owner="totocorp",repo="repo-A",runner_labels="linux,self-hosted",workflow="build",le="+Inf"} 1
+github_actions_workflow_job_queue_time_seconds_sum{owner="totocorp",repo="repo-A",runner_labels="linux,self-hosted",workflow="build"} 120
+github_actions_workflow_job_queue_time_seconds_count{owner="totocorp",repo="repo-A",runner_labels="linux,self-hosted",workflow="build"} 1
+github_actions_workflow_job_queue_time_seconds_bucket{owner="totocorp",repo="repo-A",runner_labels="ubuntu-latest",workflow="build",le="60"} 1
+github_actions_workflow_job_queue_time_seconds_bucket{owner="totocorp",repo="repo-A",runner_labels="ubuntu-latest",workflow="build",le="300"} 1
+github_actions_workflow_job_queue_time_seconds_bucket{owner="totocorp",repo="repo-A",runner_labels="ubuntu-latest",workflow="build",le="+Inf"} 1
+github_actions_workflow_job_queue_time_seconds_sum{owner="totocorp",repo="repo-A",runner_labels="ubuntu-latest",workflow="build"} 30
+github_actions_workflow_job_queue_time_seconds_count{owner="totocorp",repo="repo-A",runner_labels="ubuntu-latest",workflow="build"} 1
+# HELP github_actions_workflow_run_execution_time_seconds Time between the start and the completion of the workflow runs created during the lookback window
+# TYPE github_actions_workflow_run_execution_time_seconds histogram
+github_actions_workflow_run_execution_time_seconds_bucket{owner="totocorp",repo="repo-A",workflow="build",le="60"} 0
+github_actions_workflow_run_execution_time_seconds_bucket{owner="totocorp",repo="repo-A",workflow="build",le="300"} 2
+github_actions_workflow_run_execution_time_seconds_bucket{owner="totocorp",repo="repo-A",workflow="build",le="+Inf"} 3
+github_actions_workflow_run_execution_time_seconds_sum{owner="totocorp",repo="repo-A",workflow="build"} 960
+github_actions_workflow_run_execution_time_seconds_count{owner="totocorp",repo="repo-A",workflow="build"} 3
+# HELP github_actions_workflow_run_queue_time_seconds Time between the creation and the start of the workflow runs created during the lookback window
+# TYPE github_actions_workflow_run_queue_time_seconds histogram
+github_actions_workflow_run_queue_time_seconds_bucket{owner="totocorp",repo="repo-A",workflow="build",le="60"} 3
+github_actions_workflow_run_queue_time_seconds_bucket{owner="totocorp",repo="repo-A",workflow="build",le="300"} 4
+github_actions_workflow_run_queue_time_seconds_bucket{owner="totocorp",repo="repo-A",workflow="build",le="+Inf"} 4
+github_actions_workflow_run_queue_time_seconds_sum{owner="totocorp",repo="repo-A",workflow="build"} 300
+github_actions_workflow_run_queue_time_seconds_count{owner="totocorp",repo="repo-A",workflow="build"} 4
+`),
+		"github_actions_workflow_run_queue_time_seconds",
+		"github_actions_workflow_run_execution_time_seconds",
+		"github_actions_workflow_job_queue_time_seconds",
+		"github_actions_workflow_job_execution_time_seconds",
+	)
+	require.NoError(t, err)
+}
+
+func TestRunsCollector_Jobs(t *testing.T) {
+	var (
+		logger = zaptest.NewLogger(t)
+		gh     = github.NewClient(
+			mock.NewMockedHTTPClient(
+				mock.WithRequestMatchPages(mock.GetOrgsReposByOrg, repos...),
+				mock.WithRequestMatchPages(mock.GetReposActionsWorkflowsByOwnerByRepo, workflows...),
+				mock.WithRequestMatchHandler(
+					mock.GetReposActionsWorkflowsRunsByOwnerByRepoByWorkflowId,
+					runsHandler(t),
+				),
+				mock.WithRequestMatchHandler(
+					mock.GetReposActionsRunsJobsByOwnerByRepoByRunId,
+					jobsHandler(),
+				),
+			),
+		)
+		fetcher = actions.NewMultiOrgRunsFetcher(
+			map[string]actions.WorkflowRunsFetcher{
+				"totocorp": actions.NewOrgRunsFetcher(
+					time.Hour,
+					24*time.Hour,
+					"totocorp",
+					gh,
+					logger,
+					actions.WithWorkflowJobs(),
+				),
+			},
+			logger,
+		)
+		collector = actions.NewRunsCollector(fetcher, logger, 10*time.Minute)
+		registry  = prometheus.NewRegistry()
+	)
+
+	defer collector.Close()
+
+	err := registry.Register(collector)
+	require.NoError(t, err)
+
+	<-collector.Ready()
+
+	err = testutil.GatherAndCompare(
+		registry,
+		bytes.NewBufferString(`
+# HELP github_actions_workflow_job_duration_seconds Total execution time of the completed jobs of the workflow runs created during the lookback window, per job and runner
+# TYPE github_actions_workflow_job_duration_seconds gauge
+github_actions_workflow_job_duration_seconds{job="build",owner="totocorp",repo="repo-A",runner_group="builders",runner_labels="linux,self-hosted",workflow="build"} 180
+github_actions_workflow_job_duration_seconds{job="lint",owner="totocorp",repo="repo-A",runner_group="",runner_labels="ubuntu-latest",workflow="build"} 90
+# HELP github_actions_workflow_jobs Total of jobs of the workflow runs created during the lookback window, per job, status, conclusion and runner
+# TYPE github_actions_workflow_jobs gauge
+github_actions_workflow_jobs{conclusion="success",job="build",owner="totocorp",repo="repo-A",runner_group="builders",runner_labels="linux,self-hosted",status="completed",workflow="build"} 1
+github_actions_workflow_jobs{conclusion="success",job="lint",owner="totocorp",repo="repo-A",runner_group="",runner_labels="ubuntu-latest",status="completed",workflow="build"} 1
+`),
+		"github_actions_workflow_jobs",
+		"github_actions_workflow_job_duration_seconds",
+	)
+	require.NoError(t, err)
+}
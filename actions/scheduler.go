@@ -0,0 +1,391 @@
+package actions
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RepoLister lists the active repositories, so that their usage can be refreshed one by one.
+type RepoLister interface {
+	ListRepos(ctx context.Context) (*RepoList, error)
+}
+
+type ScheduledRepo struct {
+	Owner    string
+	Name     string
+	PushedAt time.Time
+}
+
+type RepoList struct {
+	Repos         []ScheduledRepo
+	ActiveRepos   int64
+	FilteredRepos []FilteredRepos
+
+	// Errors lists the organizations which could not be listed, their repositories are missing from Repos.
+	Errors []FetchError
+}
+
+func (l *RepoList) merge(other *RepoList) {
+	l.Repos = append(l.Repos, other.Repos...)
+	l.ActiveRepos += other.ActiveRepos
+	l.FilteredRepos = append(l.FilteredRepos, other.FilteredRepos...)
+	l.Errors = append(l.Errors, other.Errors...)
+}
+
+// StaggeredRefreshConfig configures how the usage of each repository is refreshed on its own schedule.
+type StaggeredRefreshConfig struct {
+	// HotWindow is how recently a repository must have been pushed to be considered hot.
+	HotWindow time.Duration
+	// HotPeriod is how often hot repositories are refreshed, cold ones are refreshed every refresh period.
+	HotPeriod time.Duration
+	// Concurrency bounds how many repositories can be refreshed at the same time.
+	Concurrency int
+}
+
+// repoScheduler refreshes the usage of each repository when it is due, instead of refreshing everything at once.
+// Each repository gets a stable slot within its refresh interval, derived from its name, so that refreshes
+// are spread across the interval. The active repositories are listed again every refresh period.
+type repoScheduler struct {
+	collector *UsageCollector
+	period    time.Duration
+	config    StaggeredRefreshConfig
+
+	ctx        context.Context
+	cancelFunc func()
+	ready      chan struct{}
+	done       chan struct{}
+
+	mu     sync.Mutex
+	queue  repoQueue
+	repos  map[string]*scheduledRepo
+	seeded bool
+	wake   chan struct{}
+}
+
+// startRepoScheduler does a full refresh first, unless data has been restored, then refreshes each repo when it is due.
+func startRepoScheduler(collector *UsageCollector, period time.Duration, config StaggeredRefreshConfig, restored bool) *repoScheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := repoScheduler{
+		collector:  collector,
+		period:     period,
+		config:     config,
+		ctx:        ctx,
+		cancelFunc: cancel,
+		ready:      make(chan struct{}),
+		done:       make(chan struct{}),
+		repos:      make(map[string]*scheduledRepo),
+		wake:       make(chan struct{}, 1),
+	}
+
+	if s.config.Concurrency < 1 {
+		s.config.Concurrency = 1
+	}
+
+	go func() {
+		defer close(s.done)
+
+		if !restored {
+			collector.refresh(ctx)
+		}
+
+		close(s.ready)
+
+		s.run(ctx)
+	}()
+
+	return &s
+}
+
+func (s *repoScheduler) run(ctx context.Context) {
+	var (
+		wg    sync.WaitGroup
+		slots = make(chan struct{}, s.config.Concurrency)
+
+		discoveryTicker = time.NewTicker(s.period)
+	)
+
+	defer wg.Wait()
+	defer discoveryTicker.Stop()
+
+	s.discover(ctx)
+
+	for {
+		repo, wait := s.next(time.Now())
+		if repo != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case slots <- struct{}{}:
+			}
+
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+
+				s.refreshRepo(ctx, repo)
+			}()
+
+			continue
+		}
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-discoveryTicker.C:
+			s.discover(ctx)
+		case <-s.wake:
+		case <-timer.C:
+		}
+
+		timer.Stop()
+	}
+}
+
+// next pops the next repository to refresh if it is due, otherwise it tells how long to wait for it.
+func (s *repoScheduler) next(now time.Time) (*scheduledRepo, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.queue) == 0 {
+		return nil, s.period
+	}
+
+	if wait := s.queue[0].nextDue.Sub(now); wait > 0 {
+		return nil, wait
+	}
+
+	return heap.Pop(&s.queue).(*scheduledRepo), 0
+}
+
+func (s *repoScheduler) refreshRepo(ctx context.Context, repo *scheduledRepo) {
+	result := <-s.collector.triggerRefresh(ctx, repo.Owner, repo.Name)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The repository went away while it was being refreshed.
+	if s.repos[repo.key] != repo {
+		return
+	}
+
+	// The repository is not collected anymore, or its organization is not monitored since the fetcher has been replaced.
+	if errors.Is(result.Err, ErrRepoNotCollected) || errors.Is(result.Err, ErrUnknownOwner) {
+		delete(s.repos, repo.key)
+		s.collector.dropRepo(repo.Owner, repo.Name)
+
+		return
+	}
+
+	repo.nextDue = s.nextDue(repo, time.Now())
+	heap.Push(&s.queue, repo)
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// discover lists the active repositories, schedules the new ones and forgets the ones which are gone.
+func (s *repoScheduler) discover(ctx context.Context) {
+	// The first refresh failed, there is nothing to update yet. It is tried again on the next discovery if it fails again.
+	if !s.collector.hasUsageData() {
+		s.collector.refresh(ctx)
+
+		if !s.collector.hasUsageData() {
+			return
+		}
+	}
+
+	// The fetcher can be replaced at any time, and is not guaranteed to support listing repositories.
+	lister, ok := s.collector.fetcher().(RepoLister)
+	if !ok {
+		s.collector.logger.Error("Usage fetcher does not support listing repositories, staggered refreshes are paused")
+		return
+	}
+
+	list, err := lister.ListRepos(ctx)
+
+	s.collector.applyRepoList(list, err, s.collector.nowFunc())
+
+	if err != nil {
+		return
+	}
+
+	var (
+		now          = time.Now()
+		refreshedAt  = s.dataRefreshedAt(now)
+		seen         = make(map[string]struct{}, len(list.Repos))
+		failedOwners = make(map[string]struct{})
+	)
+
+	for _, fetchErr := range list.Errors {
+		failedOwners[strings.ToLower(fetchErr.Owner)] = struct{}{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, listed := range list.Repos {
+		key := repoKey(listed.Owner, listed.Name)
+		seen[key] = struct{}{}
+
+		repo, ok := s.repos[key]
+		if !ok {
+			repo = &scheduledRepo{ScheduledRepo: listed, key: key, hash: hashKey(key)}
+			// Repositories present on the first listing are due on their next slot after the full refresh, or the
+			// restored data, that they come from. New ones are refreshed right away.
+			repo.nextDue = now
+			if !s.seeded {
+				repo.nextDue = s.nextDue(repo, refreshedAt)
+			}
+
+			s.repos[key] = repo
+			heap.Push(&s.queue, repo)
+
+			continue
+		}
+
+		pushed := listed.PushedAt.After(repo.PushedAt)
+		repo.PushedAt = listed.PushedAt
+
+		// A cold repository getting pushed becomes hot, and is due sooner.
+		if pushed && repo.index >= 0 {
+			if due := s.nextDue(repo, now); due.Before(repo.nextDue) {
+				repo.nextDue = due
+				heap.Fix(&s.queue, repo.index)
+			}
+		}
+	}
+
+	for key, repo := range s.repos {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+
+		if _, ok := failedOwners[strings.ToLower(repo.Owner)]; ok {
+			continue
+		}
+
+		delete(s.repos, key)
+
+		if repo.index >= 0 {
+			heap.Remove(&s.queue, repo.index)
+		}
+	}
+
+	s.seeded = true
+
+	s.collector.logger.Info(
+		"Scheduled repositories",
+		zap.Int("repos", len(s.repos)),
+	)
+}
+
+// dataRefreshedAt tells when the data served by the collector has been refreshed, on the clock of the scheduler.
+// Without data, it is now minus a refresh period, which makes every repository due right away.
+func (s *repoScheduler) dataRefreshedAt(now time.Time) time.Time {
+	age, ok := s.collector.dataAge()
+	if !ok {
+		return now.Add(-s.period)
+	}
+
+	return now.Add(-age)
+}
+
+// nextDue returns the next slot of the repository after now.
+func (s *repoScheduler) nextDue(repo *scheduledRepo, now time.Time) time.Time {
+	interval := s.period
+	if now.Sub(repo.PushedAt) < s.config.HotWindow {
+		interval = s.config.HotPeriod
+	}
+
+	if interval <= 0 {
+		interval = s.period
+	}
+
+	slot := now.Truncate(interval).Add(time.Duration(repo.hash) % interval)
+	if !slot.After(now) {
+		slot = slot.Add(interval)
+	}
+
+	return slot
+}
+
+func (s *repoScheduler) Close() error {
+	s.cancelFunc()
+	<-s.done
+
+	return nil
+}
+
+func (s *repoScheduler) Ready() <-chan struct{} {
+	return s.ready
+}
+
+func (s *repoScheduler) Context() context.Context {
+	return s.ctx
+}
+
+type scheduledRepo struct {
+	ScheduledRepo
+
+	key     string
+	hash    uint32
+	nextDue time.Time
+	// index in the queue, -1 while the repository is being refreshed.
+	index int
+}
+
+func repoKey(owner, repo string) string {
+	return strings.ToLower(owner + "/" + repo)
+}
+
+func hashKey(key string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+
+	return h.Sum32()
+}
+
+// repoQueue is a heap of repositories ordered by due time.
+type repoQueue []*scheduledRepo
+
+func (q repoQueue) Len() int { return len(q) }
+
+func (q repoQueue) Less(i, j int) bool { return q[i].nextDue.Before(q[j].nextDue) }
+
+func (q repoQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *repoQueue) Push(x any) {
+	repo := x.(*scheduledRepo)
+	repo.index = len(*q)
+	*q = append(*q, repo)
+}
+
+func (q *repoQueue) Pop() any {
+	old := *q
+	n := len(old)
+	repo := old[n-1]
+	old[n-1] = nil
+	repo.index = -1
+	*q = old[:n-1]
+
+	return repo
+}
@@ -0,0 +1,451 @@
+package actions_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+	"github.com/jlevesy/workflows-exporter/actions"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// scheduledUsageFetcher serves the usage of the repos returned by listRepos, and counts the fetches per repo.
+// Once a repo has been fetched maxRepoFetches times, if set, its next fetches hang until the context is done.
+// The first fullFailures full fetches fail.
+type scheduledUsageFetcher struct {
+	mu             sync.Mutex
+	listRepos      func(call int) []actions.ScheduledRepo
+	listCalls      int
+	fullFetches    int
+	fullFailures   int
+	repoFetches    map[string]int
+	repoErrors     map[string]error
+	maxRepoFetches int
+}
+
+func (f *scheduledUsageFetcher) Fetch(context.Context) (*actions.Usage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.fullFetches++
+
+	if f.fullFetches <= f.fullFailures {
+		return nil, errors.New("could not list repos")
+	}
+
+	var usage actions.Usage
+	for _, repo := range f.listRepos(0) {
+		usage.ActiveRepos++
+		usage.Workflows = append(usage.Workflows, scheduledWorkflow(repo.Name, 15*time.Second))
+	}
+
+	return &usage, nil
+}
+
+func (f *scheduledUsageFetcher) FetchRepo(ctx context.Context, _, repo string) (*actions.Usage, error) {
+	f.mu.Lock()
+
+	if f.maxRepoFetches > 0 && f.repoFetches[repo] >= f.maxRepoFetches {
+		f.mu.Unlock()
+		<-ctx.Done()
+
+		return nil, ctx.Err()
+	}
+
+	defer f.mu.Unlock()
+
+	f.repoFetches[repo]++
+
+	if err := f.repoErrors[repo]; err != nil {
+		return nil, err
+	}
+
+	return &actions.Usage{
+		Workflows: []actions.WorkflowUsage{scheduledWorkflow(repo, 30*time.Second)},
+	}, nil
+}
+
+func (f *scheduledUsageFetcher) ListRepos(context.Context) (*actions.RepoList, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	repos := f.listRepos(f.listCalls)
+	f.listCalls++
+
+	return &actions.RepoList{
+		Repos:       repos,
+		ActiveRepos: int64(len(repos)),
+	}, nil
+}
+
+func (f *scheduledUsageFetcher) counts() (fullFetches, listCalls int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.fullFetches, f.listCalls
+}
+
+func (f *scheduledUsageFetcher) repoFetchCount(repo string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.repoFetches[repo]
+}
+
+func scheduledWorkflow(repo string, billableTime time.Duration) actions.WorkflowUsage {
+	return actions.WorkflowUsage{
+		Owner:        "totocorp",
+		Repo:         repo,
+		Workflow:     "build",
+		ID:           1,
+		BillableTime: map[string]time.Duration{"UBUNTU": billableTime},
+	}
+}
+
+func TestUsageCollector_StaggeredRefresh_HotRepos(t *testing.T) {
+	var (
+		fetcher = &scheduledUsageFetcher{
+			repoFetches: make(map[string]int),
+			listRepos: func(int) []actions.ScheduledRepo {
+				return []actions.ScheduledRepo{
+					{Owner: "totocorp", Name: "repo-hot", PushedAt: time.Now()},
+					{Owner: "totocorp", Name: "repo-cold", PushedAt: time.Now().Add(-48 * time.Hour)},
+				}
+			},
+		}
+		collector = actions.NewUsageCollector(
+			fetcher,
+			zaptest.NewLogger(t),
+			time.Minute,
+			actions.WithStaggeredRefresh(actions.StaggeredRefreshConfig{
+				HotWindow:   time.Hour,
+				HotPeriod:   20 * time.Millisecond,
+				Concurrency: 2,
+			}),
+		)
+	)
+
+	<-collector.Ready()
+
+	require.Eventually(t, func() bool {
+		return fetcher.repoFetchCount("repo-hot") >= 5
+	}, 2*time.Second, 10*time.Millisecond)
+
+	err := collector.Close()
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, fetcher.fullFetches)
+	assert.Zero(t, fetcher.repoFetchCount("repo-cold"))
+}
+
+func TestUsageCollector_StaggeredRefresh_Discovery(t *testing.T) {
+	var (
+		fetcher = &scheduledUsageFetcher{
+			repoFetches: make(map[string]int),
+			listRepos: func(call int) []actions.ScheduledRepo {
+				pushedAt := time.Now().Add(-48 * time.Hour)
+
+				// repo-B goes away and repo-C shows up on the second listing.
+				if call < 2 {
+					return []actions.ScheduledRepo{
+						{Owner: "totocorp", Name: "repo-A", PushedAt: pushedAt},
+						{Owner: "totocorp", Name: "repo-B", PushedAt: pushedAt},
+					}
+				}
+
+				return []actions.ScheduledRepo{
+					{Owner: "totocorp", Name: "repo-A", PushedAt: pushedAt},
+					{Owner: "totocorp", Name: "repo-C", PushedAt: pushedAt},
+				}
+			},
+		}
+		collector = actions.NewUsageCollector(
+			fetcher,
+			zaptest.NewLogger(t),
+			50*time.Millisecond,
+			actions.WithNowFunc(fixedNow(now)),
+			actions.WithStaggeredRefresh(actions.StaggeredRefreshConfig{
+				HotWindow:   time.Hour,
+				HotPeriod:   time.Hour,
+				Concurrency: 1,
+			}),
+		)
+		registry = prometheus.NewRegistry()
+	)
+
+	defer collector.Close()
+
+	err := registry.Register(collector)
+	require.NoError(t, err)
+
+	<-collector.Ready()
+
+	// repo-A has been refreshed on its own, repo-B is gone and repo-C has been refreshed as soon as it showed up.
+	require.Eventually(t, func() bool {
+		return testutil.GatherAndCompare(
+			registry,
+			bytes.NewBufferString(`
+# HELP github_actions_workflow_billable_time_seconds Billable time for a repo, per workflow and platform
+# TYPE github_actions_workflow_billable_time_seconds gauge
+github_actions_workflow_billable_time_seconds{owner="totocorp",platform="UBUNTU",repo="repo-A",workflow="build",workflow_id="1"} 30
+github_actions_workflow_billable_time_seconds{owner="totocorp",platform="UBUNTU",repo="repo-C",workflow="build",workflow_id="1"} 30
+`),
+			"github_actions_workflow_billable_time_seconds",
+		) == nil
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestUsageCollector_StaggeredRefresh_RefreshMetrics(t *testing.T) {
+	var (
+		clock   = testClock{now: now}
+		fetcher = &scheduledUsageFetcher{
+			repoFetches: make(map[string]int),
+			repoErrors: map[string]error{
+				"repo-A": fmt.Errorf("could not get repo: %w", &github.RateLimitError{}),
+			},
+			maxRepoFetches: 1,
+			listRepos: func(call int) []actions.ScheduledRepo {
+				// repo-A shows up on the second listing, and is refreshed right away.
+				if call < 1 {
+					return nil
+				}
+
+				return []actions.ScheduledRepo{
+					{Owner: "totocorp", Name: "repo-A", PushedAt: time.Now().Add(-48 * time.Hour)},
+				}
+			},
+		}
+		collector = actions.NewUsageCollector(
+			fetcher,
+			zaptest.NewLogger(t),
+			50*time.Millisecond,
+			actions.WithNowFunc(clock.Now),
+			actions.WithSinceFunc(fixedSince(time.Second)),
+			actions.WithStaggeredRefresh(actions.StaggeredRefreshConfig{
+				HotWindow:   time.Hour,
+				HotPeriod:   time.Hour,
+				Concurrency: 1,
+			}),
+		)
+		registry = prometheus.NewRegistry()
+	)
+
+	defer collector.Close()
+
+	err := registry.Register(collector)
+	require.NoError(t, err)
+
+	<-collector.Ready()
+
+	clock.Set(now.Add(time.Minute))
+
+	// Listing repositories is not a refresh, only the first full refresh succeeded and the repo refresh failed.
+	require.Eventually(t, func() bool {
+		return testutil.GatherAndCompare(
+			registry,
+			bytes.NewBufferString(`
+# HELP github_actions_workflow_fetch_errors_total Total of errors encountered while fetching usage data, per repo and stage
+# TYPE github_actions_workflow_fetch_errors_total counter
+github_actions_workflow_fetch_errors_total{owner="totocorp",repo="repo-A",stage="fetch_repo"} 1
+# HELP github_actions_workflow_last_refresh_timestamp_seconds Last timestamp in seconds since epoch of the last dataset refresh
+# TYPE github_actions_workflow_last_refresh_timestamp_seconds gauge
+github_actions_workflow_last_refresh_timestamp_seconds 1.697328e+09
+# HELP github_actions_workflow_refresh_attempts_total Total of usage data refreshes attempted
+# TYPE github_actions_workflow_refresh_attempts_total counter
+github_actions_workflow_refresh_attempts_total 2
+# HELP github_actions_workflow_refresh_successes_total Total of usage data refreshes that succeeded, possibly with partial data
+# TYPE github_actions_workflow_refresh_successes_total counter
+github_actions_workflow_refresh_successes_total 1
+# HELP github_actions_workflow_refresh_failures_total Total of usage data refreshes that failed, per error class
+# TYPE github_actions_workflow_refresh_failures_total counter
+github_actions_workflow_refresh_failures_total{class="rate_limited"} 1
+# HELP github_actions_workflow_last_refresh_failure_timestamp_seconds Timestamp in seconds since epoch of the last failed usage data refresh
+# TYPE github_actions_workflow_last_refresh_failure_timestamp_seconds gauge
+github_actions_workflow_last_refresh_failure_timestamp_seconds 1.69732806e+09
+`),
+			"github_actions_workflow_fetch_errors_total",
+			"github_actions_workflow_last_refresh_timestamp_seconds",
+			"github_actions_workflow_refresh_attempts_total",
+			"github_actions_workflow_refresh_successes_total",
+			"github_actions_workflow_refresh_failures_total",
+			"github_actions_workflow_last_refresh_failure_timestamp_seconds",
+		) == nil
+	}, 2*time.Second, 10*time.Millisecond)
+
+}
+
+func TestUsageCollector_StaggeredRefresh_LastRefreshTime(t *testing.T) {
+	var (
+		clock   = testClock{now: now}
+		fetcher = &scheduledUsageFetcher{
+			repoFetches:    make(map[string]int),
+			maxRepoFetches: 1,
+			listRepos: func(call int) []actions.ScheduledRepo {
+				if call < 1 {
+					return nil
+				}
+
+				return []actions.ScheduledRepo{
+					{Owner: "totocorp", Name: "repo-A", PushedAt: time.Now().Add(-48 * time.Hour)},
+				}
+			},
+		}
+		collector = actions.NewUsageCollector(
+			fetcher,
+			zaptest.NewLogger(t),
+			50*time.Millisecond,
+			actions.WithNowFunc(clock.Now),
+			actions.WithSinceFunc(fixedSince(time.Second)),
+			actions.WithStaggeredRefresh(actions.StaggeredRefreshConfig{
+				HotWindow:   time.Hour,
+				HotPeriod:   time.Hour,
+				Concurrency: 1,
+			}),
+		)
+		registry = prometheus.NewRegistry()
+	)
+
+	defer collector.Close()
+
+	err := registry.Register(collector)
+	require.NoError(t, err)
+
+	<-collector.Ready()
+
+	clock.Set(now.Add(time.Minute))
+
+	// The last refresh time reports the last successful repo refresh.
+	require.Eventually(t, func() bool {
+		return testutil.GatherAndCompare(
+			registry,
+			bytes.NewBufferString(`
+# HELP github_actions_workflow_last_refresh_timestamp_seconds Last timestamp in seconds since epoch of the last dataset refresh
+# TYPE github_actions_workflow_last_refresh_timestamp_seconds gauge
+github_actions_workflow_last_refresh_timestamp_seconds 1.69732806e+09
+# HELP github_actions_workflow_refresh_successes_total Total of usage data refreshes that succeeded, possibly with partial data
+# TYPE github_actions_workflow_refresh_successes_total counter
+github_actions_workflow_refresh_successes_total 2
+`),
+			"github_actions_workflow_last_refresh_timestamp_seconds",
+			"github_actions_workflow_refresh_successes_total",
+		) == nil
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestUsageCollector_StaggeredRefresh_FirstRefresh(t *testing.T) {
+	for _, testCase := range []struct {
+		desc            string
+		fullFailures    int
+		stateAge        time.Duration
+		wantFullFetches int
+		wantListed      bool
+		wantMetrics     string
+	}{
+		{
+			desc:            "first full refresh fails, it is tried again before listing repos",
+			fullFailures:    1,
+			stateAge:        -1,
+			wantFullFetches: 2,
+			wantListed:      true,
+			wantMetrics: `
+# HELP github_actions_workflow_active_repos Last reported total of active repositories in the monitored org
+# TYPE github_actions_workflow_active_repos gauge
+github_actions_workflow_active_repos 1
+# HELP github_actions_workflow_billable_time_seconds Billable time for a repo, per workflow and platform
+# TYPE github_actions_workflow_billable_time_seconds gauge
+github_actions_workflow_billable_time_seconds{owner="totocorp",platform="UBUNTU",repo="repo-A",workflow="build",workflow_id="1"} 15
+`,
+		},
+		{
+			desc:            "full refreshes keep failing, repos are not listed",
+			fullFailures:    2,
+			stateAge:        -1,
+			wantFullFetches: 2,
+		},
+		{
+			desc:       "restored data is older than the refresh period, repos are refreshed right away",
+			stateAge:   2 * time.Hour,
+			wantListed: true,
+			wantMetrics: `
+# HELP github_actions_workflow_active_repos Last reported total of active repositories in the monitored org
+# TYPE github_actions_workflow_active_repos gauge
+github_actions_workflow_active_repos 1
+# HELP github_actions_workflow_billable_time_seconds Billable time for a repo, per workflow and platform
+# TYPE github_actions_workflow_billable_time_seconds gauge
+github_actions_workflow_billable_time_seconds{owner="totocorp",platform="UBUNTU",repo="repo-A",workflow="build",workflow_id="1"} 30
+`,
+		},
+	} {
+		t.Run(testCase.desc, func(t *testing.T) {
+			var (
+				statePath = filepath.Join(t.TempDir(), "state.json")
+				fetcher   = &scheduledUsageFetcher{
+					repoFetches:  make(map[string]int),
+					fullFailures: testCase.fullFailures,
+					listRepos: func(int) []actions.ScheduledRepo {
+						return []actions.ScheduledRepo{
+							{Owner: "totocorp", Name: "repo-A", PushedAt: time.Now().Add(-48 * time.Hour)},
+						}
+					},
+				}
+				registry = prometheus.NewRegistry()
+			)
+
+			if testCase.stateAge >= 0 {
+				writeState(t, statePath, now.Add(-testCase.stateAge))
+			}
+
+			collector := actions.NewUsageCollector(
+				fetcher,
+				zaptest.NewLogger(t),
+				time.Hour,
+				actions.WithNowFunc(fixedNow(now)),
+				actions.WithSinceFunc(fixedSince(time.Second)),
+				actions.WithStateFile(statePath, 3*time.Hour),
+				actions.WithStaggeredRefresh(actions.StaggeredRefreshConfig{
+					HotWindow:   time.Hour,
+					HotPeriod:   time.Hour,
+					Concurrency: 1,
+				}),
+			)
+
+			defer collector.Close()
+
+			err := registry.Register(collector)
+			require.NoError(t, err)
+
+			require.Eventually(t, func() bool {
+				fullFetches, listCalls := fetcher.counts()
+
+				return fullFetches == testCase.wantFullFetches && (listCalls > 0) == testCase.wantListed
+			}, 2*time.Second, 10*time.Millisecond)
+
+			if !testCase.wantListed {
+				// Give the scheduler a chance to list repos, which it must not do.
+				time.Sleep(50 * time.Millisecond)
+
+				_, listCalls := fetcher.counts()
+				assert.Zero(t, listCalls)
+			}
+
+			require.Eventually(t, func() bool {
+				return testutil.GatherAndCompare(
+					registry,
+					bytes.NewBufferString(testCase.wantMetrics),
+					"github_actions_workflow_active_repos",
+					"github_actions_workflow_billable_time_seconds",
+				) == nil
+			}, 2*time.Second, 10*time.Millisecond)
+		})
+	}
+}
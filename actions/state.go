@@ -0,0 +1,54 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jlevesy/workflows-exporter/pkg/atomicfile"
+)
+
+const usageSnapshotVersion = 1
+
+// usageSnapshot is what the UsageCollector persists to its state file after each successful refresh.
+type usageSnapshot struct {
+	Version         int           `json:"version"`
+	RefreshTime     time.Time     `json:"refresh_time"`
+	RefreshDuration time.Duration `json:"refresh_duration"`
+	Usage           *Usage        `json:"usage"`
+}
+
+func loadUsageSnapshot(path string) (*usageSnapshot, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot usageSnapshot
+	if err := json.Unmarshal(content, &snapshot); err != nil {
+		return nil, fmt.Errorf("invalid state file %q: %w", path, err)
+	}
+
+	if snapshot.Version != usageSnapshotVersion {
+		return nil, fmt.Errorf("unsupported state file version %d", snapshot.Version)
+	}
+
+	if snapshot.Usage == nil {
+		return nil, fmt.Errorf("invalid state file %q: no usage data", path)
+	}
+
+	return &snapshot, nil
+}
+
+func saveUsageSnapshot(path string, snapshot usageSnapshot) error {
+	snapshot.Version = usageSnapshotVersion
+
+	content, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	// A crash while writing must never leave a truncated state file behind.
+	return atomicfile.WriteFile(path, content)
+}
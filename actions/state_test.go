@@ -0,0 +1,174 @@
+package actions_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jlevesy/workflows-exporter/actions"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+type usageFetcherFunc func(ctx context.Context) (*actions.Usage, error)
+
+func (f usageFetcherFunc) Fetch(ctx context.Context) (*actions.Usage, error) { return f(ctx) }
+
+var stateUsage = actions.Usage{
+	ActiveRepos: 1,
+	Workflows: []actions.WorkflowUsage{
+		{
+			Owner:        "totocorp",
+			Repo:         "repo-A",
+			Workflow:     "build",
+			ID:           1,
+			BillableTime: map[string]time.Duration{"UBUNTU": 15 * time.Second},
+		},
+	},
+}
+
+func writeState(t *testing.T, path string, refreshTime time.Time) {
+	t.Helper()
+
+	content, err := json.Marshal(map[string]any{
+		"version":          1,
+		"refresh_time":     refreshTime,
+		"refresh_duration": time.Second,
+		"usage":            stateUsage,
+	})
+	require.NoError(t, err)
+
+	err = os.WriteFile(path, content, 0o600)
+	require.NoError(t, err)
+}
+
+func TestUsageCollector_StateFile(t *testing.T) {
+	for _, testCase := range []struct {
+		desc           string
+		stateAge       time.Duration
+		wantFetch      bool
+		wantMetrics    string
+		wantStateSaved bool
+	}{
+		{
+			desc:     "fresh state is restored, and served right away",
+			stateAge: time.Minute,
+			wantMetrics: `
+# HELP github_actions_workflow_billable_time_seconds Billable time for a repo, per workflow and platform
+# TYPE github_actions_workflow_billable_time_seconds gauge
+github_actions_workflow_billable_time_seconds{owner="totocorp",platform="UBUNTU",repo="repo-A",workflow="build",workflow_id="1"} 15
+# HELP github_actions_workflow_last_refresh_timestamp_seconds Last timestamp in seconds since epoch of the last dataset refresh
+# TYPE github_actions_workflow_last_refresh_timestamp_seconds gauge
+github_actions_workflow_last_refresh_timestamp_seconds 1.69732794e+09
+`,
+		},
+		{
+			desc:           "stale state is refreshed before being ready",
+			stateAge:       time.Hour,
+			wantFetch:      true,
+			wantStateSaved: true,
+			wantMetrics: `
+# HELP github_actions_workflow_billable_time_seconds Billable time for a repo, per workflow and platform
+# TYPE github_actions_workflow_billable_time_seconds gauge
+github_actions_workflow_billable_time_seconds{owner="totocorp",platform="UBUNTU",repo="repo-A",workflow="build",workflow_id="1"} 30
+# HELP github_actions_workflow_last_refresh_timestamp_seconds Last timestamp in seconds since epoch of the last dataset refresh
+# TYPE github_actions_workflow_last_refresh_timestamp_seconds gauge
+github_actions_workflow_last_refresh_timestamp_seconds 1.697328e+09
+`,
+		},
+		{
+			desc:           "missing state is created after the first refresh",
+			stateAge:       -1,
+			wantFetch:      true,
+			wantStateSaved: true,
+			wantMetrics: `
+# HELP github_actions_workflow_billable_time_seconds Billable time for a repo, per workflow and platform
+# TYPE github_actions_workflow_billable_time_seconds gauge
+github_actions_workflow_billable_time_seconds{owner="totocorp",platform="UBUNTU",repo="repo-A",workflow="build",workflow_id="1"} 30
+# HELP github_actions_workflow_last_refresh_timestamp_seconds Last timestamp in seconds since epoch of the last dataset refresh
+# TYPE github_actions_workflow_last_refresh_timestamp_seconds gauge
+github_actions_workflow_last_refresh_timestamp_seconds 1.697328e+09
+`,
+		},
+	} {
+		t.Run(testCase.desc, func(t *testing.T) {
+			var (
+				logger    = zaptest.NewLogger(t)
+				statePath = filepath.Join(t.TempDir(), "state.json")
+				fetched   bool
+				fetcher   = usageFetcherFunc(func(context.Context) (*actions.Usage, error) {
+					fetched = true
+
+					return &actions.Usage{
+						ActiveRepos: 1,
+						Workflows: []actions.WorkflowUsage{
+							{
+								Owner:        "totocorp",
+								Repo:         "repo-A",
+								Workflow:     "build",
+								ID:           1,
+								BillableTime: map[string]time.Duration{"UBUNTU": 30 * time.Second},
+							},
+						},
+					}, nil
+				})
+			)
+
+			if testCase.stateAge >= 0 {
+				writeState(t, statePath, now.Add(-testCase.stateAge))
+			}
+
+			collector := actions.NewUsageCollector(
+				fetcher,
+				logger,
+				10*time.Minute,
+				actions.WithSinceFunc(fixedSince(time.Second)),
+				actions.WithNowFunc(fixedNow(now)),
+				actions.WithStateFile(statePath, 0),
+			)
+			defer collector.Close()
+
+			registry := prometheus.NewRegistry()
+
+			err := registry.Register(collector)
+			require.NoError(t, err)
+
+			<-collector.Ready()
+
+			assert.Equal(t, testCase.wantFetch, fetched)
+
+			err = testutil.GatherAndCompare(
+				registry,
+				bytes.NewBufferString(testCase.wantMetrics),
+				"github_actions_workflow_billable_time_seconds",
+				"github_actions_workflow_last_refresh_timestamp_seconds",
+			)
+			require.NoError(t, err)
+
+			if !testCase.wantStateSaved {
+				return
+			}
+
+			content, err := os.ReadFile(statePath)
+			require.NoError(t, err)
+
+			var saved struct {
+				RefreshTime time.Time     `json:"refresh_time"`
+				Usage       actions.Usage `json:"usage"`
+			}
+
+			err = json.Unmarshal(content, &saved)
+			require.NoError(t, err)
+
+			assert.True(t, now.Equal(saved.RefreshTime))
+			assert.Equal(t, 30*time.Second, saved.Usage.Workflows[0].BillableTime["UBUNTU"])
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package actions
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+type StorageFetcher = Fetcher[Storage]
+
+type RepoCacheUsage struct {
+	Owner string
+	Repo  string
+
+	ActiveCachesCount int64
+	ActiveCachesBytes int64
+}
+
+type RepoArtifacts struct {
+	Owner string
+	Repo  string
+
+	// Expired artifacts are not stored anymore, they are not accounted for.
+	Count int64
+	Bytes int64
+}
+
+type Storage struct {
+	Caches    []RepoCacheUsage
+	Artifacts []RepoArtifacts
+
+	// Errors lists everything that could not be fetched during this refresh.
+	// When not empty, the storage data is partial.
+	Errors []FetchError
+}
+
+func (s *Storage) merge(other *Storage) {
+	s.Caches = append(s.Caches, other.Caches...)
+	s.Artifacts = append(s.Artifacts, other.Artifacts...)
+	s.Errors = append(s.Errors, other.Errors...)
+}
+
+func (s *Storage) addError(fetchErr FetchError) {
+	s.Errors = append(s.Errors, fetchErr)
+}
+
+func NewMultiOrgStorageFetcher(fetchers map[string]StorageFetcher, logger *zap.Logger) *MultiOrgFetcher[Storage] {
+	return &MultiOrgFetcher[Storage]{
+		fetchers: fetchers,
+		logger:   logger,
+		merge:    (*Storage).merge,
+		addError: (*Storage).addError,
+	}
+}
+
+// OrgStorageFetcher reports the Actions cache and artifacts storage of the active repositories of an organization.
+type OrgStorageFetcher struct {
+	gh     *github.Client
+	logger *zap.Logger
+
+	org     string
+	config  fetcherConfig
+	scanner repoScanner
+}
+
+func NewOrgStorageFetcher(maxLastPushed time.Duration, org string, gh *github.Client, logger *zap.Logger, opts ...FetcherOpt) *OrgStorageFetcher {
+	config := newFetcherConfig(opts)
+
+	return &OrgStorageFetcher{
+		org:    org,
+		gh:     gh,
+		logger: logger,
+		config: config,
+		scanner: repoScanner{
+			org:           org,
+			gh:            gh,
+			maxLastPushed: maxLastPushed,
+			filter:        config.repoFilter,
+			logger:        logger,
+		},
+	}
+}
+
+func (f *OrgStorageFetcher) Fetch(ctx context.Context) (*Storage, error) {
+	var (
+		storageMu sync.Mutex
+		storage   Storage
+
+		recordError = func(fetchErr FetchError) {
+			logFetchError(f.logger, fetchErr)
+
+			storageMu.Lock()
+			storage.addError(fetchErr)
+			storageMu.Unlock()
+		}
+
+		repoGroup errgroup.Group
+	)
+
+	// The cache usage of all repositories is listed in a few calls at the organization level,
+	// repositories without any cache are not part of the list.
+	cacheUsage := make(map[string]*github.ActionsCacheUsage)
+	cacheErr := scanAllOrgCacheUsage(ctx, f.org, f.gh.Actions, func(usages []*github.ActionsCacheUsage) {
+		for _, usage := range usages {
+			cacheUsage[strings.ToLower(usage.FullName)] = usage
+		}
+	})
+	if cacheErr != nil {
+		recordError(FetchError{
+			Owner: f.org,
+			Stage: StageCacheUsage,
+			Err:   cacheErr,
+		})
+	}
+
+	repoGroup.SetLimit(f.config.repoConcurrency)
+
+	_, scanErr := f.scanner.scan(ctx, func(repo *github.Repository) {
+		if cacheErr == nil {
+			repoCache := RepoCacheUsage{Owner: f.org, Repo: repo.GetName()}
+
+			if usage, ok := cacheUsage[strings.ToLower(f.org+"/"+repo.GetName())]; ok {
+				repoCache.ActiveCachesCount = int64(usage.ActiveCachesCount)
+				repoCache.ActiveCachesBytes = usage.ActiveCachesSizeInBytes
+			}
+
+			storageMu.Lock()
+			storage.Caches = append(storage.Caches, repoCache)
+			storageMu.Unlock()
+		}
+
+		repoGroup.Go(func() error {
+			artifacts := RepoArtifacts{Owner: f.org, Repo: repo.GetName()}
+
+			err := scanAllRepoArtifacts(ctx, f.org, repo.GetName(), f.gh.Actions, func(batch []*github.Artifact) {
+				for _, artifact := range batch {
+					if artifact.GetExpired() {
+						continue
+					}
+
+					artifacts.Count++
+					artifacts.Bytes += artifact.GetSizeInBytes()
+				}
+			})
+			if err != nil {
+				recordError(FetchError{
+					Owner: f.org,
+					Repo:  repo.GetName(),
+					Stage: StageListArtifacts,
+					Err:   err,
+				})
+
+				return nil
+			}
+
+			storageMu.Lock()
+			storage.Artifacts = append(storage.Artifacts, artifacts)
+			storageMu.Unlock()
+
+			return nil
+		})
+	})
+
+	_ = repoGroup.Wait()
+
+	if scanErr != nil {
+		return nil, scanErr
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return &storage, nil
+}
+
+func scanAllOrgCacheUsage(ctx context.Context, org string, actionsClient *github.ActionsService, cb func([]*github.ActionsCacheUsage)) error {
+	var nextPage int
+
+	for {
+		usageBatch, resp, err := actionsClient.ListCacheUsageByRepoForOrg(
+			ctx,
+			org,
+			&github.ListOptions{
+				Page:    nextPage,
+				PerPage: 100,
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		cb(usageBatch.RepoCacheUsage)
+
+		if resp.NextPage == 0 {
+			return nil
+		}
+
+		nextPage = resp.NextPage
+	}
+}
+
+func scanAllRepoArtifacts(ctx context.Context, org, repo string, actionsClient *github.ActionsService, cb func([]*github.Artifact)) error {
+	var nextPage int
+
+	for {
+		artifactsBatch, resp, err := actionsClient.ListArtifacts(
+			ctx,
+			org,
+			repo,
+			&github.ListOptions{
+				Page:    nextPage,
+				PerPage: 100,
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		cb(artifactsBatch.Artifacts)
+
+		if resp.NextPage == 0 {
+			return nil
+		}
+
+		nextPage = resp.NextPage
+	}
+}
@@ -0,0 +1,161 @@
+package actions
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+type StorageCollector struct {
+	cacheCountDesc    *prometheus.Desc
+	cacheSizeDesc     *prometheus.Desc
+	artifactCountDesc *prometheus.Desc
+	artifactSizeDesc  *prometheus.Desc
+
+	loop *refreshLoop
+
+	storageFetcherMu sync.RWMutex
+	storageFetcher   StorageFetcher
+
+	lastStorageDataMu sync.RWMutex
+	lastStorageData   *Storage
+
+	logger *zap.Logger
+}
+
+func NewStorageCollector(storageFetcher StorageFetcher, logger *zap.Logger, refreshPeriod time.Duration) *StorageCollector {
+	c := StorageCollector{
+		logger:         logger,
+		storageFetcher: storageFetcher,
+
+		cacheCountDesc: prometheus.NewDesc(
+			"github_actions_cache_active_count",
+			"Last reported total of active Actions caches for a repo",
+			[]string{"owner", "repo"},
+			nil,
+		),
+		cacheSizeDesc: prometheus.NewDesc(
+			"github_actions_cache_active_size_bytes",
+			"Last reported size in bytes of the active Actions caches for a repo",
+			[]string{"owner", "repo"},
+			nil,
+		),
+		artifactCountDesc: prometheus.NewDesc(
+			"github_actions_artifacts_count",
+			"Last reported total of stored, non expired, artifacts for a repo",
+			[]string{"owner", "repo"},
+			nil,
+		),
+		artifactSizeDesc: prometheus.NewDesc(
+			"github_actions_artifacts_size_bytes",
+			"Last reported size in bytes of the stored, non expired, artifacts for a repo",
+			[]string{"owner", "repo"},
+			nil,
+		),
+	}
+
+	c.loop = startRefreshLoop(refreshPeriod, c.refresh)
+
+	return &c
+}
+
+func (c *StorageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.cacheCountDesc
+	ch <- c.cacheSizeDesc
+	ch <- c.artifactCountDesc
+	ch <- c.artifactSizeDesc
+}
+
+func (c *StorageCollector) Collect(ch chan<- prometheus.Metric) {
+	c.lastStorageDataMu.RLock()
+	defer c.lastStorageDataMu.RUnlock()
+
+	if c.lastStorageData == nil {
+		return
+	}
+
+	for _, cache := range c.lastStorageData.Caches {
+		ch <- prometheus.MustNewConstMetric(
+			c.cacheCountDesc,
+			prometheus.GaugeValue,
+			float64(cache.ActiveCachesCount),
+			cache.Owner,
+			cache.Repo,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.cacheSizeDesc,
+			prometheus.GaugeValue,
+			float64(cache.ActiveCachesBytes),
+			cache.Owner,
+			cache.Repo,
+		)
+	}
+
+	for _, artifacts := range c.lastStorageData.Artifacts {
+		ch <- prometheus.MustNewConstMetric(
+			c.artifactCountDesc,
+			prometheus.GaugeValue,
+			float64(artifacts.Count),
+			artifacts.Owner,
+			artifacts.Repo,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.artifactSizeDesc,
+			prometheus.GaugeValue,
+			float64(artifacts.Bytes),
+			artifacts.Owner,
+			artifacts.Repo,
+		)
+	}
+}
+
+func (c *StorageCollector) Close() error {
+	return c.loop.Close()
+}
+
+func (c *StorageCollector) Ready() <-chan struct{} {
+	return c.loop.Ready()
+}
+
+// SetFetcher replaces the storage fetcher, for instance when the configuration changes. It is used from the next refresh on.
+func (c *StorageCollector) SetFetcher(fetcher StorageFetcher) {
+	c.storageFetcherMu.Lock()
+	defer c.storageFetcherMu.Unlock()
+
+	c.storageFetcher = fetcher
+}
+
+func (c *StorageCollector) fetcher() StorageFetcher {
+	c.storageFetcherMu.RLock()
+	defer c.storageFetcherMu.RUnlock()
+
+	return c.storageFetcher
+}
+
+func (c *StorageCollector) refresh(ctx context.Context) {
+	c.logger.Info("Refreshing storage data")
+
+	storageData, err := c.fetcher().Fetch(ctx)
+	if err != nil {
+		c.logger.Error(
+			"Could not retrieve updated storage data",
+			zap.Error(err),
+		)
+
+		return
+	}
+
+	c.logger.Info(
+		"Done refreshing storage data",
+		zap.Int("caches", len(storageData.Caches)),
+		zap.Int("artifacts", len(storageData.Artifacts)),
+		zap.Int("errors", len(storageData.Errors)),
+	)
+
+	c.lastStorageDataMu.Lock()
+	c.lastStorageData = storageData
+	c.lastStorageDataMu.Unlock()
+}
@@ -0,0 +1,111 @@
+package actions_test
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+	"github.com/jlevesy/workflows-exporter/actions"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+var orgCacheUsage = github.ActionsCacheUsageList{
+	TotalCount: 2,
+	RepoCacheUsage: []*github.ActionsCacheUsage{
+		{
+			FullName:                "totocorp/repo-A",
+			ActiveCachesCount:       3,
+			ActiveCachesSizeInBytes: 1024,
+		},
+		{
+			FullName:                "totocorp/repo-D",
+			ActiveCachesCount:       1,
+			ActiveCachesSizeInBytes: 512,
+		},
+	},
+}
+
+// artifactsHandler serves two artifacts for repo-A, one being expired, and nothing for the others.
+func artifactsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/totocorp/repo-A/actions/artifacts" {
+			_, _ = w.Write(mock.MustMarshal(github.ArtifactList{}))
+			return
+		}
+
+		_, _ = w.Write(mock.MustMarshal(github.ArtifactList{
+			TotalCount: ptr(int64(2)),
+			Artifacts: []*github.Artifact{
+				{ID: ptr(int64(1)), SizeInBytes: ptr(int64(2048)), Expired: ptr(false)},
+				{ID: ptr(int64(2)), SizeInBytes: ptr(int64(4096)), Expired: ptr(true)},
+			},
+		}))
+	})
+}
+
+func TestStorageCollector(t *testing.T) {
+	var (
+		logger = zaptest.NewLogger(t)
+		gh     = github.NewClient(
+			mock.NewMockedHTTPClient(
+				mock.WithRequestMatchPages(mock.GetOrgsReposByOrg, repos...),
+				mock.WithRequestMatchPages(mock.GetOrgsActionsCacheUsageByRepositoryByOrg, orgCacheUsage),
+				mock.WithRequestMatchHandler(
+					mock.GetReposActionsArtifactsByOwnerByRepo,
+					failingHandler("/repos/totocorp/repo-B/", artifactsHandler()),
+				),
+			),
+		)
+		fetcher = actions.NewMultiOrgStorageFetcher(
+			map[string]actions.StorageFetcher{
+				"totocorp": actions.NewOrgStorageFetcher(
+					24*time.Hour,
+					"totocorp",
+					gh,
+					logger,
+				),
+			},
+			logger,
+		)
+		collector = actions.NewStorageCollector(fetcher, logger, 10*time.Minute)
+		registry  = prometheus.NewRegistry()
+	)
+
+	defer collector.Close()
+
+	err := registry.Register(collector)
+	require.NoError(t, err)
+
+	<-collector.Ready()
+
+	err = testutil.GatherAndCompare(
+		registry,
+		bytes.NewBufferString(`
+# HELP github_actions_artifacts_count Last reported total of stored, non expired, artifacts for a repo
+# TYPE github_actions_artifacts_count gauge
+github_actions_artifacts_count{owner="totocorp",repo="repo-A"} 1
+github_actions_artifacts_count{owner="totocorp",repo="repo-C"} 0
+# HELP github_actions_artifacts_size_bytes Last reported size in bytes of the stored, non expired, artifacts for a repo
+# TYPE github_actions_artifacts_size_bytes gauge
+github_actions_artifacts_size_bytes{owner="totocorp",repo="repo-A"} 2048
+github_actions_artifacts_size_bytes{owner="totocorp",repo="repo-C"} 0
+# HELP github_actions_cache_active_count Last reported total of active Actions caches for a repo
+# TYPE github_actions_cache_active_count gauge
+github_actions_cache_active_count{owner="totocorp",repo="repo-A"} 3
+github_actions_cache_active_count{owner="totocorp",repo="repo-B"} 0
+github_actions_cache_active_count{owner="totocorp",repo="repo-C"} 0
+# HELP github_actions_cache_active_size_bytes Last reported size in bytes of the active Actions caches for a repo
+# TYPE github_actions_cache_active_size_bytes gauge
+github_actions_cache_active_size_bytes{owner="totocorp",repo="repo-A"} 1024
+github_actions_cache_active_size_bytes{owner="totocorp",repo="repo-B"} 0
+github_actions_cache_active_size_bytes{owner="totocorp",repo="repo-C"} 0
+`),
+	)
+	require.NoError(t, err)
+}
@@ -2,7 +2,8 @@ package actions
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,9 +12,9 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
-type WorkflowUsageFetcher interface {
-	Fetch(ctx context.Context) (*Usage, error)
-}
+type WorkflowUsageFetcher = Fetcher[Usage]
+
+type RepoUsageFetcher = RepoFetcher[Usage]
 
 type WorkflowUsage struct {
 	Owner    string
@@ -27,193 +28,356 @@ type WorkflowUsage struct {
 type Usage struct {
 	ActiveRepos int64
 	Workflows   []WorkflowUsage
+
+	// FilteredRepos counts the active repositories ignored by the RepoFilter, per reason.
+	FilteredRepos []FilteredRepos
+
+	// Errors lists everything that could not be fetched during this refresh.
+	// When not empty, the usage data is partial.
+	// They are not persisted in the state file, errors do not round trip through JSON.
+	Errors []FetchError `json:"-"`
+}
+
+func (u *Usage) merge(other *Usage) {
+	u.ActiveRepos += other.ActiveRepos
+	u.Workflows = append(u.Workflows, other.Workflows...)
+	u.FilteredRepos = append(u.FilteredRepos, other.FilteredRepos...)
+	u.Errors = append(u.Errors, other.Errors...)
+}
+
+func (u *Usage) addError(fetchErr FetchError) {
+	u.Errors = append(u.Errors, fetchErr)
+}
+
+// withRepo returns a copy of the usage where the workflows and errors of a repo are replaced by the ones of from.
+func (u *Usage) withRepo(owner, repo string, from *Usage) *Usage {
+	result := u.filterRepos(func(o, r string) bool {
+		return !strings.EqualFold(o, owner) || !strings.EqualFold(r, repo)
+	})
+
+	result.Workflows = append(result.Workflows, from.Workflows...)
+	result.Errors = append(result.Errors, from.Errors...)
+
+	return result
+}
+
+// filterRepos returns a copy of the usage only keeping the workflows and errors of the repos matching keep.
+func (u *Usage) filterRepos(keep func(owner, repo string) bool) *Usage {
+	result := Usage{
+		ActiveRepos:   u.ActiveRepos,
+		FilteredRepos: u.FilteredRepos,
+	}
+
+	for _, workflow := range u.Workflows {
+		if keep(workflow.Owner, workflow.Repo) {
+			result.Workflows = append(result.Workflows, workflow)
+		}
+	}
+
+	for _, fetchErr := range u.Errors {
+		if keep(fetchErr.Owner, fetchErr.Repo) {
+			result.Errors = append(result.Errors, fetchErr)
+		}
+	}
+
+	return &result
 }
 
 type OrgUsageFetcher struct {
 	gh     *github.Client
 	logger *zap.Logger
 
-	maxLastPushed time.Duration
-	org           string
+	org     string
+	config  fetcherConfig
+	scanner repoScanner
+
+	// cache is only set when incremental refreshes are enabled.
+	cache *repoUsageCache
 }
 
-func NewOrgUsageFetcher(maxLastPushed time.Duration, org string, gh *github.Client, logger *zap.Logger) *OrgUsageFetcher {
+func NewOrgUsageFetcher(maxLastPushed time.Duration, org string, gh *github.Client, logger *zap.Logger, opts ...FetcherOpt) *OrgUsageFetcher {
+	config := newFetcherConfig(opts)
+
+	var cache *repoUsageCache
+	if config.incrementalMaxAge > 0 {
+		cache = newRepoUsageCache(config.incrementalMaxAge)
+	}
+
 	return &OrgUsageFetcher{
-		maxLastPushed: maxLastPushed,
-		org:           org,
-		gh:            gh,
-		logger:        logger,
+		cache:  cache,
+		org:    org,
+		gh:     gh,
+		logger: logger,
+		config: config,
+		scanner: repoScanner{
+			org:           org,
+			gh:            gh,
+			maxLastPushed: maxLastPushed,
+			filter:        config.repoFilter,
+			logger:        logger,
+		},
 	}
 }
 
+// InheritCache carries the incremental refresh cache of the previous fetcher of the same organization over, so that
+// replacing the fetcher when the configuration changes does not fetch all the repositories again.
+// Entries keep their fetch time, and the max age of f applies to them. Nothing is done unless both fetchers
+// refresh incrementally.
+func (f *OrgUsageFetcher) InheritCache(previous *OrgUsageFetcher) {
+	if f.cache == nil || previous == nil || previous.cache == nil || !strings.EqualFold(f.org, previous.org) {
+		return
+	}
+
+	f.cache.copyFrom(previous.cache)
+}
+
 func (f *OrgUsageFetcher) Fetch(ctx context.Context) (*Usage, error) {
 	var (
 		usageMu sync.Mutex
 		usage   Usage
 
-		group, groupCtx = errgroup.WithContext(ctx)
+		recordError = func(fetchErr FetchError) {
+			logFetchError(f.logger, fetchErr)
+
+			usageMu.Lock()
+			usage.addError(fetchErr)
+			usageMu.Unlock()
+		}
+
+		// Two distinct groups are needed here: repo goroutines are spawning workflow goroutines,
+		// sharing a single limited group would deadlock as soon as all slots are taken by repos.
+		// Calls to Go block once the limit is reached, which throttles the producers.
+		repoGroup     errgroup.Group
+		workflowGroup errgroup.Group
 	)
 
-	group.Go(func() error {
-		return scanAllOrgRepos(
-			groupCtx,
-			f.org,
-			f.gh.Repositories,
-			func(reposBatch []*github.Repository) error {
-				var totalInactive int
-
-				f.logger.Info(
-					"New batch of repositories",
-					zap.Int("length", len(reposBatch)),
-				)
-
-				for _, repo := range reposBatch {
-					if time.Since(repo.GetPushedAt().Time) >= f.maxLastPushed {
-						totalInactive++
-						continue
-					}
+	repoGroup.SetLimit(f.config.repoConcurrency)
+	workflowGroup.SetLimit(f.config.workflowConcurrency)
 
-					// No mutex needed here, only one goroutine in writing this integer.
-					usage.ActiveRepos++
-
-					repo := repo
-
-					group.Go(func() error {
-						return scanAllRepoWorkflows(
-							ctx,
-							f.org,
-							repo.GetName(),
-							f.gh.Actions,
-							func(workflows *github.Workflows) {
-								f.logger.Debug(
-									"Collecting data for repo",
-									zap.String("owner", f.org),
-									zap.String("repo", repo.GetName()),
-									zap.Int("workflow_count", workflows.GetTotalCount()),
-								)
-
-								for _, workflow := range workflows.Workflows {
-									workflow := workflow
-
-									group.Go(func() error {
-										workflowUsage, _, err := f.gh.Actions.GetWorkflowUsageByID(
-											ctx,
-											f.org,
-											repo.GetName(),
-											workflow.GetID(),
-										)
-										if err != nil {
-											return err
-										}
-
-										result := WorkflowUsage{
-											Owner:    f.org,
-											Repo:     repo.GetName(),
-											Workflow: workflow.GetName(),
-											ID:       workflow.GetID(),
-											BillableTime: makeBillableTime(
-												workflowUsage.GetBillable(),
-											),
-										}
-
-										usageMu.Lock()
-										usage.Workflows = append(usage.Workflows, result)
-										usageMu.Unlock()
-
-										f.logger.Debug(
-											"Collected usage Info",
-											zap.String("owner", f.org),
-											zap.String("repo", repo.GetName()),
-											zap.String("workflow", workflow.GetName()),
-										)
-
-										return nil
-									})
-								}
-							},
-						)
-					})
-				}
-
-				// Don't scan for all repos, if all are inactive in a single batch
-				// and because we're scanning in pushed_at descending order
-				// then we can consider the job done.
-				if totalInactive == len(reposBatch) {
-					f.logger.Info("Got a full batch of inactive repositories, exiting")
-					return errEarlyExit
-				}
-
-				return nil
-			},
-		)
-	})
+	var (
+		fetchTime   = time.Now()
+		activeRepos = make(map[string]struct{})
+		fetched     []*github.Repository
+	)
 
-	return &usage, group.Wait()
-}
+	scanResult, scanErr := f.scanner.scan(ctx, func(repo *github.Repository) {
+		activeRepos[repo.GetName()] = struct{}{}
 
-func scanAllRepoWorkflows(ctx context.Context, org, repo string, workflowClient *github.ActionsService, cb func(*github.Workflows)) error {
-	var nextPage int
+		if f.cache != nil {
+			if workflows, ok := f.cache.lookup(repo, fetchTime); ok {
+				usageMu.Lock()
+				usage.Workflows = append(usage.Workflows, workflows...)
+				usageMu.Unlock()
 
-	for {
-		workflowBatch, resp, err := workflowClient.ListWorkflows(
-			ctx,
-			org,
-			repo,
-			&github.ListOptions{
-				Page:    nextPage,
-				PerPage: 10,
-			},
-		)
+				return
+			}
 
-		if err != nil {
-			return err
+			fetched = append(fetched, repo)
 		}
 
-		cb(workflowBatch)
+		repoGroup.Go(func() error {
+			f.fetchRepoWorkflows(ctx, repo.GetName(), &workflowGroup, &usageMu, &usage, recordError)
 
-		if resp.NextPage == 0 {
 			return nil
-		}
+		})
+	})
 
-		nextPage = resp.NextPage
+	// Workflow goroutines are all spawned by repo goroutines, so waiting
+	// for repos first guarantees that no workflow goroutine is missed.
+	_ = repoGroup.Wait()
+	_ = workflowGroup.Wait()
+
+	if scanErr != nil {
+		return nil, scanErr
+	}
+
+	// If the refresh has been cancelled, all remaining calls failed for
+	// the same reason, which does not make a meaningful partial result.
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-}
 
-var errEarlyExit = errors.New("early exit")
+	usage.ActiveRepos = scanResult.activeRepos
+	usage.FilteredRepos = scanResult.filteredRepos
 
-func scanAllOrgRepos(ctx context.Context, org string, reposClient *github.RepositoriesService, cb func([]*github.Repository) error) error {
-	var nextPage int
+	if f.cache != nil {
+		f.updateCache(&usage, fetched, fetchTime)
+		f.cache.retain(activeRepos)
 
-	for {
-		reposBatch, resp, err := reposClient.ListByOrg(
-			ctx,
-			org,
-			&github.RepositoryListByOrgOptions{
-				Sort:      "pushed",
-				Direction: "desc",
-				ListOptions: github.ListOptions{
-					Page:    nextPage,
-					PerPage: 100,
-				},
-			},
+		f.logger.Info(
+			"Incremental refresh done",
+			zap.String("owner", f.org),
+			zap.Int("fetched_repos", len(fetched)),
+			zap.Int("cached_repos", len(activeRepos)-len(fetched)),
 		)
-		if err != nil {
-			return err
-		}
+	}
 
-		err = cb(reposBatch)
-		switch {
-		case errors.Is(err, errEarlyExit):
-			return nil
-		case err != nil:
-			return err
-		}
+	return &usage, nil
+}
 
-		if resp.NextPage == 0 {
-			return nil
+// updateCache stores the workflows of the repositories fetched during this refresh.
+// Repositories which failed are dropped from the cache, so that they are fetched again next time.
+func (f *OrgUsageFetcher) updateCache(usage *Usage, fetched []*github.Repository, fetchTime time.Time) {
+	var (
+		workflows = make(map[string][]WorkflowUsage, len(fetched))
+		failed    = make(map[string]struct{})
+	)
+
+	for _, workflow := range usage.Workflows {
+		workflows[workflow.Repo] = append(workflows[workflow.Repo], workflow)
+	}
+
+	for _, fetchErr := range usage.Errors {
+		failed[fetchErr.Repo] = struct{}{}
+	}
+
+	for _, repo := range fetched {
+		if _, ok := failed[repo.GetName()]; ok {
+			f.cache.drop(repo.GetName())
+			continue
 		}
 
-		nextPage = resp.NextPage
+		f.cache.store(repo, fetchTime, workflows[repo.GetName()])
+	}
+}
+
+// ListRepos lists the active repositories matching the filter, without fetching their usage.
+func (f *OrgUsageFetcher) ListRepos(ctx context.Context) (*RepoList, error) {
+	var list RepoList
+
+	scanResult, err := f.scanner.scan(ctx, func(repo *github.Repository) {
+		list.Repos = append(list.Repos, ScheduledRepo{
+			Owner:    f.org,
+			Name:     repo.GetName(),
+			PushedAt: repo.GetPushedAt().Time,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	list.ActiveRepos = scanResult.activeRepos
+	list.FilteredRepos = scanResult.filteredRepos
+
+	return &list, nil
+}
+
+// FetchRepo only retrieves the usage of the workflows of a single repository.
+// ActiveRepos and FilteredRepos are left empty, as they only make sense for a whole organization.
+func (f *OrgUsageFetcher) FetchRepo(ctx context.Context, owner, repo string) (*Usage, error) {
+	if !strings.EqualFold(owner, f.org) {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownOwner, owner)
+	}
+
+	ghRepo, err := f.scanner.get(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		usageMu sync.Mutex
+		usage   Usage
+
+		fetchTime     = time.Now()
+		workflowGroup errgroup.Group
+	)
+
+	workflowGroup.SetLimit(f.config.workflowConcurrency)
+
+	f.fetchRepoWorkflows(ctx, ghRepo.GetName(), &workflowGroup, &usageMu, &usage, func(fetchErr FetchError) {
+		logFetchError(f.logger, fetchErr)
+
+		usageMu.Lock()
+		usage.addError(fetchErr)
+		usageMu.Unlock()
+	})
+
+	_ = workflowGroup.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if f.cache != nil {
+		f.updateCache(&usage, []*github.Repository{ghRepo}, fetchTime)
+	}
+
+	return &usage, nil
+}
+
+// fetchRepoWorkflows lists the workflows of a repo, and spawns a call to retrieve the usage of each of them in workflowGroup.
+// The caller must wait for workflowGroup for usage to be complete.
+func (f *OrgUsageFetcher) fetchRepoWorkflows(ctx context.Context, repo string, workflowGroup *errgroup.Group, usageMu *sync.Mutex, usage *Usage, recordError func(FetchError)) {
+	err := scanAllRepoWorkflows(
+		ctx,
+		f.org,
+		repo,
+		f.gh.Actions,
+		func(workflows *github.Workflows) {
+			f.logger.Debug(
+				"Collecting data for repo",
+				zap.String("owner", f.org),
+				zap.String("repo", repo),
+				zap.Int("workflow_count", workflows.GetTotalCount()),
+			)
+
+			for _, workflow := range workflows.Workflows {
+				workflow := workflow
+
+				workflowGroup.Go(func() error {
+					workflowUsage, _, err := f.gh.Actions.GetWorkflowUsageByID(
+						ctx,
+						f.org,
+						repo,
+						workflow.GetID(),
+					)
+					if err != nil {
+						// Keep going, a single workflow failing should not
+						// invalidate the whole dataset.
+						recordError(FetchError{
+							Owner:    f.org,
+							Repo:     repo,
+							Workflow: workflow.GetName(),
+							Stage:    StageWorkflowUsage,
+							Err:      err,
+						})
+
+						return nil
+					}
+
+					result := WorkflowUsage{
+						Owner:    f.org,
+						Repo:     repo,
+						Workflow: workflow.GetName(),
+						ID:       workflow.GetID(),
+						BillableTime: makeBillableTime(
+							workflowUsage.GetBillable(),
+						),
+					}
+
+					usageMu.Lock()
+					usage.Workflows = append(usage.Workflows, result)
+					usageMu.Unlock()
+
+					f.logger.Debug(
+						"Collected usage Info",
+						zap.String("owner", f.org),
+						zap.String("repo", repo),
+						zap.String("workflow", workflow.GetName()),
+					)
+
+					return nil
+				})
+			}
+		},
+	)
+	if err != nil {
+		recordError(FetchError{
+			Owner: f.org,
+			Repo:  repo,
+			Stage: StageListWorkflows,
+			Err:   err,
+		})
 	}
 }
 
@@ -226,3 +390,14 @@ func makeBillableTime(ghBillableTime *github.WorkflowBillMap) map[string]time.Du
 
 	return result
 }
+
+func logFetchError(logger *zap.Logger, fetchErr FetchError) {
+	logger.Warn(
+		"Could not collect data",
+		zap.String("owner", fetchErr.Owner),
+		zap.String("repo", fetchErr.Repo),
+		zap.String("workflow", fetchErr.Workflow),
+		zap.String("stage", fetchErr.Stage),
+		zap.Error(fetchErr.Err),
+	)
+}
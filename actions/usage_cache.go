@@ -0,0 +1,93 @@
+package actions
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+)
+
+// repoUsageCache remembers the workflows usage of each repository of an organization, with
+// when it has been fetched and the pushed_at of the repository at that time.
+type repoUsageCache struct {
+	maxAge time.Duration
+
+	mu      sync.Mutex
+	entries map[string]repoUsageEntry
+}
+
+type repoUsageEntry struct {
+	pushedAt  time.Time
+	fetchedAt time.Time
+	workflows []WorkflowUsage
+}
+
+func newRepoUsageCache(maxAge time.Duration) *repoUsageCache {
+	return &repoUsageCache{
+		maxAge:  maxAge,
+		entries: make(map[string]repoUsageEntry),
+	}
+}
+
+// lookup returns the cached workflows of a repository, unless it has been pushed since they have been fetched, or if they are too old.
+func (c *repoUsageCache) lookup(repo *github.Repository, now time.Time) ([]WorkflowUsage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[repo.GetName()]
+	if !ok {
+		return nil, false
+	}
+
+	if repo.GetPushedAt().Time.After(entry.pushedAt) || now.Sub(entry.fetchedAt) >= c.maxAge {
+		return nil, false
+	}
+
+	return entry.workflows, true
+}
+
+func (c *repoUsageCache) store(repo *github.Repository, fetchedAt time.Time, workflows []WorkflowUsage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[repo.GetName()] = repoUsageEntry{
+		pushedAt:  repo.GetPushedAt().Time,
+		fetchedAt: fetchedAt,
+		workflows: workflows,
+	}
+}
+
+// drop forgets a repository, so that it is fully fetched next time.
+func (c *repoUsageCache) drop(repo string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, repo)
+}
+
+// retain forgets the repositories which are not active anymore, or filtered out.
+func (c *repoUsageCache) retain(repos map[string]struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for repo := range c.entries {
+		if _, ok := repos[repo]; !ok {
+			delete(c.entries, repo)
+		}
+	}
+}
+
+// copyFrom adds the entries of other, which are not known yet.
+func (c *repoUsageCache) copyFrom(other *repoUsageCache) {
+	other.mu.Lock()
+	defer other.mu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for repo, entry := range other.entries {
+		if _, ok := c.entries[repo]; !ok {
+			c.entries[repo] = entry
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package actions_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+	"github.com/jlevesy/workflows-exporter/actions"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestOrgUsageFetcher_Concurrency(t *testing.T) {
+	for _, testCase := range []struct {
+		desc                string
+		repoConcurrency     int
+		workflowConcurrency int
+	}{
+		{
+			desc:                "sequential",
+			repoConcurrency:     1,
+			workflowConcurrency: 1,
+		},
+		{
+			desc:                "bounded",
+			repoConcurrency:     2,
+			workflowConcurrency: 3,
+		},
+	} {
+		t.Run(testCase.desc, func(t *testing.T) {
+			var (
+				workflowsInFlight = newInFlightCounter(paginatedHandler(workflows...))
+				timingsInFlight   = newInFlightCounter(paginatedHandler(workflowTiming))
+
+				logger = zaptest.NewLogger(t)
+				gh     = github.NewClient(
+					mock.NewMockedHTTPClient(
+						mock.WithRequestMatchPages(mock.GetOrgsReposByOrg, repos...),
+						mock.WithRequestMatchHandler(
+							mock.GetReposActionsWorkflowsByOwnerByRepo,
+							workflowsInFlight,
+						),
+						mock.WithRequestMatchHandler(
+							mock.GetReposActionsWorkflowsTimingByOwnerByRepoByWorkflowId,
+							timingsInFlight,
+						),
+					),
+				)
+				fetcher = actions.NewOrgUsageFetcher(
+					24*time.Hour,
+					"totocorp",
+					gh,
+					logger,
+					actions.WithRepoConcurrency(testCase.repoConcurrency),
+					actions.WithWorkflowConcurrency(testCase.workflowConcurrency),
+				)
+			)
+
+			usage, err := fetcher.Fetch(context.Background())
+			require.NoError(t, err)
+
+			assert.Len(t, usage.Workflows, 12)
+			assert.LessOrEqual(t, workflowsInFlight.max, testCase.repoConcurrency)
+			assert.LessOrEqual(t, timingsInFlight.max, testCase.workflowConcurrency)
+		})
+	}
+}
+
+func TestOrgUsageFetcher_FetchRepo(t *testing.T) {
+	for _, testCase := range []struct {
+		desc          string
+		owner         string
+		repo          github.Repository
+		opts          []actions.FetcherOpt
+		wantErr       error
+		wantWorkflows int
+	}{
+		{
+			desc:  "fetches the workflows of the repo",
+			owner: "TotoCorp",
+			repo: github.Repository{
+				Name:     github.String("repo-A"),
+				PushedAt: &github.Timestamp{Time: time.Now().Add(-time.Hour)},
+			},
+			wantWorkflows: 4,
+		},
+		{
+			desc:    "rejects unknown owners",
+			owner:   "acme",
+			wantErr: actions.ErrUnknownOwner,
+		},
+		{
+			desc:  "rejects inactive repos",
+			owner: "totocorp",
+			repo: github.Repository{
+				Name:     github.String("repo-A"),
+				PushedAt: &github.Timestamp{Time: time.Now().Add(-48 * time.Hour)},
+			},
+			wantErr: actions.ErrRepoNotCollected,
+		},
+		{
+			desc:  "rejects filtered repos",
+			owner: "totocorp",
+			repo: github.Repository{
+				Name:     github.String("repo-A"),
+				PushedAt: &github.Timestamp{Time: time.Now().Add(-time.Hour)},
+				Archived: github.Bool(true),
+			},
+			opts: []actions.FetcherOpt{
+				actions.WithRepoFilter(actions.RepoFilter{SkipArchived: true}),
+			},
+			wantErr: actions.ErrRepoNotCollected,
+		},
+	} {
+		t.Run(testCase.desc, func(t *testing.T) {
+			var (
+				gh = github.NewClient(
+					mock.NewMockedHTTPClient(
+						mock.WithRequestMatch(mock.GetReposByOwnerByRepo, testCase.repo),
+						mock.WithRequestMatchPages(mock.GetReposActionsWorkflowsByOwnerByRepo, workflows...),
+						mock.WithRequestMatchHandler(
+							mock.GetReposActionsWorkflowsTimingByOwnerByRepoByWorkflowId,
+							paginatedHandler(workflowTiming),
+						),
+					),
+				)
+				fetcher = actions.NewOrgUsageFetcher(
+					24*time.Hour,
+					"totocorp",
+					gh,
+					zaptest.NewLogger(t),
+					testCase.opts...,
+				)
+			)
+
+			usage, err := fetcher.FetchRepo(context.Background(), testCase.owner, "repo-A")
+			if testCase.wantErr != nil {
+				require.ErrorIs(t, err, testCase.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, usage.Workflows, testCase.wantWorkflows)
+
+			for _, workflow := range usage.Workflows {
+				assert.Equal(t, "totocorp", workflow.Owner)
+				assert.Equal(t, "repo-A", workflow.Repo)
+				assert.Equal(t, 15*time.Second, workflow.BillableTime["UBUNTU"])
+			}
+		})
+	}
+}
+
+func TestOrgUsageFetcher_IncrementalRefresh(t *testing.T) {
+	for _, testCase := range []struct {
+		desc            string
+		maxAge          time.Duration
+		pushRepoA       bool
+		failRepoB       bool
+		replaceFetcher  bool
+		wantRefetched   []string
+		wantWorkflows   int
+		wantSecondError bool
+	}{
+		{
+			desc:          "serves unchanged repos from the previous fetch",
+			maxAge:        time.Hour,
+			wantWorkflows: 8,
+		},
+		{
+			desc:          "fetches again pushed repos",
+			maxAge:        time.Hour,
+			pushRepoA:     true,
+			wantRefetched: []string{"repo-A"},
+			wantWorkflows: 8,
+		},
+		{
+			desc:          "fetches again repos older than the max age",
+			maxAge:        time.Nanosecond,
+			wantRefetched: []string{"repo-A", "repo-B"},
+			wantWorkflows: 8,
+		},
+		{
+			desc:           "keeps the cache of a replaced fetcher",
+			maxAge:         time.Hour,
+			replaceFetcher: true,
+			wantWorkflows:  8,
+		},
+		{
+			desc:            "fetches again repos which failed",
+			maxAge:          time.Hour,
+			failRepoB:       true,
+			wantRefetched:   []string{"repo-B"},
+			wantWorkflows:   4,
+			wantSecondError: true,
+		},
+	} {
+		t.Run(testCase.desc, func(t *testing.T) {
+			var (
+				mu            sync.Mutex
+				repoAPushedAt = time.Now().Add(-time.Hour)
+				repoBPushedAt = time.Now().Add(-2 * time.Hour)
+				listedRepos   []string
+
+				reposHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+					mu.Lock()
+					defer mu.Unlock()
+
+					_, _ = w.Write(mock.MustMarshal([]github.Repository{
+						{
+							Name:     github.String("repo-A"),
+							PushedAt: &github.Timestamp{Time: repoAPushedAt},
+						},
+						{
+							Name:     github.String("repo-B"),
+							PushedAt: &github.Timestamp{Time: repoBPushedAt},
+						},
+					}))
+				})
+				workflowsHandler = http.Handler(paginatedHandler(workflows...))
+			)
+
+			if testCase.failRepoB {
+				workflowsHandler = failingHandler("/repos/totocorp/repo-B/", workflowsHandler)
+			}
+
+			var (
+				gh = github.NewClient(
+					mock.NewMockedHTTPClient(
+						mock.WithRequestMatchHandler(mock.GetOrgsReposByOrg, reposHandler),
+						mock.WithRequestMatchHandler(
+							mock.GetReposActionsWorkflowsByOwnerByRepo,
+							http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+								// Only count the first page of each listing.
+								if r.URL.Query().Get("page") == "" {
+									mu.Lock()
+									listedRepos = append(listedRepos, strings.Split(r.URL.Path, "/")[3])
+									mu.Unlock()
+								}
+
+								workflowsHandler.ServeHTTP(w, r)
+							}),
+						),
+						mock.WithRequestMatchHandler(
+							mock.GetReposActionsWorkflowsTimingByOwnerByRepoByWorkflowId,
+							paginatedHandler(workflowTiming),
+						),
+					),
+				)
+				fetcher = actions.NewOrgUsageFetcher(
+					24*time.Hour,
+					"totocorp",
+					gh,
+					zaptest.NewLogger(t),
+					actions.WithIncrementalRefresh(testCase.maxAge),
+				)
+			)
+
+			_, err := fetcher.Fetch(context.Background())
+			require.NoError(t, err)
+
+			mu.Lock()
+			assert.ElementsMatch(t, []string{"repo-A", "repo-B"}, listedRepos)
+			listedRepos = nil
+			if testCase.pushRepoA {
+				repoAPushedAt = time.Now()
+			}
+			mu.Unlock()
+
+			if testCase.replaceFetcher {
+				replaced := fetcher
+				fetcher = actions.NewOrgUsageFetcher(
+					24*time.Hour,
+					"totocorp",
+					gh,
+					zaptest.NewLogger(t),
+					actions.WithIncrementalRefresh(testCase.maxAge),
+				)
+				fetcher.InheritCache(replaced)
+			}
+
+			usage, err := fetcher.Fetch(context.Background())
+			require.NoError(t, err)
+
+			assert.ElementsMatch(t, testCase.wantRefetched, listedRepos)
+			assert.Len(t, usage.Workflows, testCase.wantWorkflows)
+			assert.Equal(t, testCase.wantSecondError, len(usage.Errors) > 0)
+		})
+	}
+}
+
+type inFlightCounter struct {
+	next http.Handler
+
+	mu      sync.Mutex
+	current int
+	max     int
+}
+
+func newInFlightCounter(next http.Handler) *inFlightCounter {
+	return &inFlightCounter{next: next}
+}
+
+func (c *inFlightCounter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	c.current++
+	if c.current > c.max {
+		c.max = c.current
+	}
+	c.mu.Unlock()
+
+	// Give a chance to other requests to pile up.
+	time.Sleep(5 * time.Millisecond)
+
+	c.next.ServeHTTP(w, r)
+
+	c.mu.Lock()
+	c.current--
+	c.mu.Unlock()
+}
@@ -0,0 +1,148 @@
+package actions
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/google/go-github/v57/github"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+const (
+	webhookResultProcessed        = "processed"
+	webhookResultIgnored          = "ignored"
+	webhookResultInvalidSignature = "invalid_signature"
+	webhookResultInvalidPayload   = "invalid_payload"
+
+	// webhookEventOther labels the event types which are not expected, the header is set by the sender.
+	webhookEventOther = "other"
+
+	// maxWebhookPayloadBytes matches the largest payload GitHub delivers.
+	maxWebhookPayloadBytes = 25 << 20
+)
+
+var webhookEventTypes = []string{"workflow_run", "workflow_job", "ping"}
+
+// RunsObserver ingests the runs and jobs reported by webhook events, it reports if they have been accounted for.
+type RunsObserver interface {
+	ObserveRun(run WorkflowRun) bool
+	ObserveJob(job WorkflowJob) bool
+}
+
+// WebhookReceiver ingests workflow_run and workflow_job webhook events, and forwards them to the runs collector
+// so that its metrics are updated in real time. Runs and jobs are identified by their ID, redelivered events
+// do not count twice, and the next refresh reconciles them with the polled data.
+type WebhookReceiver struct {
+	secret   []byte
+	observer RunsObserver
+	logger   *zap.Logger
+
+	events *prometheus.CounterVec
+}
+
+func NewWebhookReceiver(secret []byte, observer RunsObserver, logger *zap.Logger) *WebhookReceiver {
+	return &WebhookReceiver{
+		secret:   secret,
+		observer: observer,
+		logger:   logger,
+
+		events: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "github_actions_webhook_events_total",
+				Help: "Total of webhook events received, per event type and result",
+			},
+			[]string{"event", "result"},
+		),
+	}
+}
+
+func (r *WebhookReceiver) Describe(ch chan<- *prometheus.Desc) {
+	r.events.Describe(ch)
+}
+
+func (r *WebhookReceiver) Collect(ch chan<- prometheus.Metric) {
+	r.events.Collect(ch)
+}
+
+func (r *WebhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	req.Body = http.MaxBytesReader(w, req.Body, maxWebhookPayloadBytes)
+
+	var (
+		eventType  = github.WebHookType(req)
+		eventLabel = webhookEventOther
+	)
+
+	if slices.Contains(webhookEventTypes, eventType) {
+		eventLabel = eventType
+	}
+
+	payload, err := github.ValidatePayload(req, r.secret)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			r.events.WithLabelValues(eventLabel, webhookResultInvalidPayload).Inc()
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		r.logger.Warn("Rejected webhook event", zap.String("event", eventType), zap.Error(err))
+		r.events.WithLabelValues(eventLabel, webhookResultInvalidSignature).Inc()
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	event, err := github.ParseWebHook(eventType, payload)
+	if err != nil {
+		r.logger.Warn("Could not parse webhook event", zap.String("event", eventType), zap.Error(err))
+		r.events.WithLabelValues(eventLabel, webhookResultInvalidPayload).Inc()
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var processed bool
+
+	switch event := event.(type) {
+	case *github.WorkflowRunEvent:
+		processed = r.handleWorkflowRun(event)
+	case *github.WorkflowJobEvent:
+		processed = r.handleWorkflowJob(event)
+	}
+
+	result := webhookResultIgnored
+	if processed {
+		result = webhookResultProcessed
+	}
+
+	r.events.WithLabelValues(eventLabel, result).Inc()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (r *WebhookReceiver) handleWorkflowRun(event *github.WorkflowRunEvent) bool {
+	var (
+		run      = event.GetWorkflowRun()
+		workflow = event.GetWorkflow()
+	)
+
+	if workflow == nil {
+		workflow = &github.Workflow{ID: run.WorkflowID, Name: run.Name}
+	}
+
+	return r.observer.ObserveRun(
+		makeWorkflowRun(event.GetRepo().GetOwner().GetLogin(), event.GetRepo().GetName(), workflow, run),
+	)
+}
+
+func (r *WebhookReceiver) handleWorkflowJob(event *github.WorkflowJobEvent) bool {
+	job := event.GetWorkflowJob()
+
+	return r.observer.ObserveJob(
+		makeWorkflowJob(event.GetRepo().GetOwner().GetLogin(), event.GetRepo().GetName(), job.GetWorkflowName(), job),
+	)
+}
@@ -0,0 +1,340 @@
+package actions_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jlevesy/workflows-exporter/actions"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+var webhookSecret = []byte("s3cr3t")
+
+type runsFetcherFunc func(ctx context.Context) (*actions.Runs, error)
+
+func (f runsFetcherFunc) Fetch(ctx context.Context) (*actions.Runs, error) {
+	return f(ctx)
+}
+
+type webhookDelivery struct {
+	event      string
+	payload    string
+	secret     []byte
+	wantStatus int
+}
+
+func TestWebhookReceiver(t *testing.T) {
+	var (
+		createdAt = time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+		polled    = actions.Runs{
+			Repos: []actions.CollectedRepo{{Owner: "totocorp", Name: "repo-A"}},
+			Runs: []actions.WorkflowRun{
+				{
+					Owner:      "totocorp",
+					Repo:       "repo-A",
+					Workflow:   "build",
+					WorkflowID: 1,
+					ID:         100,
+					Status:     "in_progress",
+					Event:      "push",
+					Branch:     "main",
+					CreatedAt:  createdAt,
+					StartedAt:  createdAt.Add(30 * time.Second),
+					UpdatedAt:  createdAt.Add(time.Minute),
+				},
+			},
+		}
+	)
+
+	for _, testCase := range []struct {
+		desc        string
+		polled      actions.Runs
+		lookback    time.Duration
+		now         time.Time
+		deliveries  []webhookDelivery
+		wantMetrics string
+		metricNames []string
+	}{
+		{
+			desc:   "updates polled runs, once per run",
+			polled: polled,
+			deliveries: []webhookDelivery{
+				{
+					event:      "workflow_run",
+					payload:    "workflow_run_completed.json",
+					secret:     webhookSecret,
+					wantStatus: http.StatusAccepted,
+				},
+				// Redelivery of the same event.
+				{
+					event:      "workflow_run",
+					payload:    "workflow_run_completed.json",
+					secret:     webhookSecret,
+					wantStatus: http.StatusAccepted,
+				},
+			},
+			wantMetrics: `
+# HELP github_actions_webhook_events_total Total of webhook events received, per event type and result
+# TYPE github_actions_webhook_events_total counter
+github_actions_webhook_events_total{event="workflow_run",result="processed"} 2
+# HELP github_actions_workflow_runs Total of workflow runs created during the lookback window, per status and conclusion
+# TYPE github_actions_workflow_runs gauge
+github_actions_workflow_runs{branch="main",conclusion="success",event="push",owner="totocorp",repo="repo-A",status="completed",workflow="build"} 1
+# HELP github_actions_workflow_run_execution_time_seconds Time between the start and the completion of the workflow runs created during the lookback window
+# TYPE github_actions_workflow_run_execution_time_seconds histogram
+github_actions_workflow_run_execution_time_seconds_bucket{owner="totocorp",repo="repo-A",workflow="build",le="60"} 0
+github_actions_workflow_run_execution_time_seconds_bucket{owner="totocorp",repo="repo-A",workflow="build",le="300"} 1
+github_actions_workflow_run_execution_time_seconds_bucket{owner="totocorp",repo="repo-A",workflow="build",le="+Inf"} 1
+github_actions_workflow_run_execution_time_seconds_sum{owner="totocorp",repo="repo-A",workflow="build"} 300
+github_actions_workflow_run_execution_time_seconds_count{owner="totocorp",repo="repo-A",workflow="build"} 1
+`,
+			metricNames: []string{
+				"github_actions_webhook_events_total",
+				"github_actions_workflow_runs",
+				"github_actions_workflow_run_execution_time_seconds",
+			},
+		},
+		{
+			desc:   "records jobs in real time",
+			polled: polled,
+			deliveries: []webhookDelivery{
+				{
+					event:      "workflow_job",
+					payload:    "workflow_job_queued.json",
+					secret:     webhookSecret,
+					wantStatus: http.StatusAccepted,
+				},
+				{
+					event:      "workflow_job",
+					payload:    "workflow_job_completed.json",
+					secret:     webhookSecret,
+					wantStatus: http.StatusAccepted,
+				},
+			},
+			wantMetrics: `
+# HELP github_actions_webhook_events_total Total of webhook events received, per event type and result
+# TYPE github_actions_webhook_events_total counter
+github_actions_webhook_events_total{event="workflow_job",result="processed"} 2
+# HELP github_actions_workflow_jobs Total of jobs of the workflow runs created during the lookback window, per job, status, conclusion and runner
+# TYPE github_actions_workflow_jobs gauge
+github_actions_workflow_jobs{conclusion="",job="lint",owner="totocorp",repo="repo-A",runner_group="",runner_labels="ubuntu-latest",status="queued",workflow="build"} 1
+github_actions_workflow_jobs{conclusion="failure",job="test",owner="totocorp",repo="repo-A",runner_group="builders",runner_labels="linux,self-hosted",status="completed",workflow="build"} 1
+# HELP github_actions_workflow_job_duration_seconds Total execution time of the completed jobs of the workflow runs created during the lookback window, per job and runner
+# TYPE github_actions_workflow_job_duration_seconds gauge
+github_actions_workflow_job_duration_seconds{job="test",owner="totocorp",repo="repo-A",runner_group="builders",runner_labels="linux,self-hosted",workflow="build"} 240
+`,
+			metricNames: []string{
+				"github_actions_webhook_events_total",
+				"github_actions_workflow_jobs",
+				"github_actions_workflow_job_duration_seconds",
+			},
+		},
+		{
+			desc: "ignores repositories which are not collected",
+			polled: actions.Runs{
+				Repos: []actions.CollectedRepo{{Owner: "totocorp", Name: "repo-B"}},
+			},
+			deliveries: []webhookDelivery{
+				{
+					event:      "workflow_run",
+					payload:    "workflow_run_completed.json",
+					secret:     webhookSecret,
+					wantStatus: http.StatusAccepted,
+				},
+			},
+			wantMetrics: `
+# HELP github_actions_webhook_events_total Total of webhook events received, per event type and result
+# TYPE github_actions_webhook_events_total counter
+github_actions_webhook_events_total{event="workflow_run",result="ignored"} 1
+`,
+			metricNames: []string{
+				"github_actions_webhook_events_total",
+				"github_actions_workflow_runs",
+			},
+		},
+		{
+			desc:     "ignores runs and jobs created before the lookback window",
+			polled:   polled,
+			lookback: time.Hour,
+			now:      createdAt.Add(time.Hour + 10*time.Second),
+			deliveries: []webhookDelivery{
+				{
+					event:      "workflow_run",
+					payload:    "workflow_run_completed.json",
+					secret:     webhookSecret,
+					wantStatus: http.StatusAccepted,
+				},
+				// The job is created during the window, but its run is not.
+				{
+					event:      "workflow_job",
+					payload:    "workflow_job_completed.json",
+					secret:     webhookSecret,
+					wantStatus: http.StatusAccepted,
+				},
+			},
+			wantMetrics: `
+# HELP github_actions_webhook_events_total Total of webhook events received, per event type and result
+# TYPE github_actions_webhook_events_total counter
+github_actions_webhook_events_total{event="workflow_job",result="ignored"} 1
+github_actions_webhook_events_total{event="workflow_run",result="ignored"} 1
+# HELP github_actions_workflow_runs Total of workflow runs created during the lookback window, per status and conclusion
+# TYPE github_actions_workflow_runs gauge
+github_actions_workflow_runs{branch="main",conclusion="",event="push",owner="totocorp",repo="repo-A",status="in_progress",workflow="build"} 1
+`,
+			metricNames: []string{
+				"github_actions_webhook_events_total",
+				"github_actions_workflow_runs",
+				"github_actions_workflow_jobs",
+			},
+		},
+		{
+			desc:     "accepts runs created during the lookback window",
+			polled:   polled,
+			lookback: time.Hour,
+			now:      createdAt.Add(50 * time.Minute),
+			deliveries: []webhookDelivery{
+				{
+					event:      "workflow_run",
+					payload:    "workflow_run_completed.json",
+					secret:     webhookSecret,
+					wantStatus: http.StatusAccepted,
+				},
+			},
+			wantMetrics: `
+# HELP github_actions_webhook_events_total Total of webhook events received, per event type and result
+# TYPE github_actions_webhook_events_total counter
+github_actions_webhook_events_total{event="workflow_run",result="processed"} 1
+# HELP github_actions_workflow_runs Total of workflow runs created during the lookback window, per status and conclusion
+# TYPE github_actions_workflow_runs gauge
+github_actions_workflow_runs{branch="main",conclusion="success",event="push",owner="totocorp",repo="repo-A",status="completed",workflow="build"} 1
+`,
+			metricNames: []string{
+				"github_actions_webhook_events_total",
+				"github_actions_workflow_runs",
+			},
+		},
+		{
+			desc:   "rejects invalid signatures",
+			polled: polled,
+			deliveries: []webhookDelivery{
+				{
+					event:      "workflow_run",
+					payload:    "workflow_run_completed.json",
+					secret:     []byte("not-the-secret"),
+					wantStatus: http.StatusUnauthorized,
+				},
+				{
+					event:      "made_up_event",
+					payload:    "ping.json",
+					secret:     []byte("not-the-secret"),
+					wantStatus: http.StatusUnauthorized,
+				},
+			},
+			wantMetrics: `
+# HELP github_actions_webhook_events_total Total of webhook events received, per event type and result
+# TYPE github_actions_webhook_events_total counter
+github_actions_webhook_events_total{event="other",result="invalid_signature"} 1
+github_actions_webhook_events_total{event="workflow_run",result="invalid_signature"} 1
+`,
+			metricNames: []string{
+				"github_actions_webhook_events_total",
+			},
+		},
+		{
+			desc:   "ignores other events",
+			polled: polled,
+			deliveries: []webhookDelivery{
+				{
+					event:      "ping",
+					payload:    "ping.json",
+					secret:     webhookSecret,
+					wantStatus: http.StatusAccepted,
+				},
+			},
+			wantMetrics: `
+# HELP github_actions_webhook_events_total Total of webhook events received, per event type and result
+# TYPE github_actions_webhook_events_total counter
+github_actions_webhook_events_total{event="ping",result="ignored"} 1
+`,
+			metricNames: []string{
+				"github_actions_webhook_events_total",
+			},
+		},
+	} {
+		t.Run(testCase.desc, func(t *testing.T) {
+			opts := []actions.RunsCollectorOpt{
+				actions.WithDurationBuckets([]float64{60, 300}),
+				actions.WithJobs(),
+			}
+			if testCase.lookback > 0 {
+				opts = append(
+					opts,
+					actions.WithRunsLookback(testCase.lookback),
+					actions.WithRunsNowFunc(fixedNow(testCase.now)),
+				)
+			}
+
+			var (
+				logger    = zaptest.NewLogger(t)
+				collector = actions.NewRunsCollector(
+					runsFetcherFunc(func(context.Context) (*actions.Runs, error) {
+						return &testCase.polled, nil
+					}),
+					logger,
+					time.Hour,
+					opts...,
+				)
+				receiver = actions.NewWebhookReceiver(webhookSecret, collector, logger)
+				registry = prometheus.NewRegistry()
+			)
+
+			defer collector.Close()
+
+			<-collector.Ready()
+
+			registry.MustRegister(receiver, collector)
+
+			for _, delivery := range testCase.deliveries {
+				payload, err := os.ReadFile(filepath.Join("testdata", "webhook", delivery.payload))
+				require.NoError(t, err)
+
+				req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(payload))
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("X-GitHub-Event", delivery.event)
+				req.Header.Set("X-Hub-Signature-256", sign(delivery.secret, payload))
+
+				rec := httptest.NewRecorder()
+				receiver.ServeHTTP(rec, req)
+
+				assert.Equal(t, delivery.wantStatus, rec.Code)
+			}
+
+			err := testutil.GatherAndCompare(
+				registry,
+				bytes.NewBufferString(testCase.wantMetrics),
+				testCase.metricNames...,
+			)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func sign(secret, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write(payload)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,558 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jlevesy/workflows-exporter/actions"
+	"github.com/jlevesy/workflows-exporter/pkg/github"
+	"gopkg.in/yaml.v3"
+)
+
+// config holds every setting of the exporter. It is read from the file given by -config if any,
+// then flags explicitly set on the command line take precedence over the file.
+type config struct {
+	ListenAddress string        `yaml:"listen_address"`
+	Pprof         bool          `yaml:"pprof"`
+	ShutdownDelay time.Duration `yaml:"shutdown_delay"`
+	RefreshPeriod time.Duration `yaml:"refresh_period"`
+	MaxLastPushed time.Duration `yaml:"max_last_pushed"`
+
+	Organizations []orgConfig  `yaml:"organizations"`
+	Filters       filterConfig `yaml:"filters"`
+
+	RepoConcurrency     int                    `yaml:"repo_concurrency"`
+	WorkflowConcurrency int                    `yaml:"workflow_concurrency"`
+	IncrementalMaxAge   time.Duration          `yaml:"incremental_max_age"`
+	StaggeredRefresh    staggeredRefreshConfig `yaml:"staggered_refresh"`
+
+	GitHub githubConfig `yaml:"github"`
+
+	Collect         collectConfig `yaml:"collect"`
+	DurationBuckets []float64     `yaml:"duration_buckets"`
+
+	WebhookSecret string `yaml:"webhook_secret"`
+	AdminToken    string `yaml:"admin_token"`
+
+	PriceTableFile string             `yaml:"price_table_file"`
+	PriceTable     actions.PriceTable `yaml:"price_table"`
+
+	StateFile   string        `yaml:"state_file"`
+	StateMaxAge time.Duration `yaml:"state_max_age"`
+
+	HealthMaxRefreshPeriods float64       `yaml:"health_max_refresh_periods"`
+	StaleThreshold          time.Duration `yaml:"stale_threshold"`
+}
+
+// orgConfig holds the settings of a single organization, unset ones default to the global settings.
+type orgConfig struct {
+	Name          string        `yaml:"name"`
+	MaxLastPushed time.Duration `yaml:"max_last_pushed"`
+	Filters       *filterConfig `yaml:"filters"`
+}
+
+type filterConfig struct {
+	Include       []string `yaml:"include"`
+	Exclude       []string `yaml:"exclude"`
+	RequireTopics []string `yaml:"require_topics"`
+	ForbidTopics  []string `yaml:"forbid_topics"`
+	Visibility    []string `yaml:"visibility"`
+	SkipArchived  bool     `yaml:"skip_archived"`
+	SkipForks     bool     `yaml:"skip_forks"`
+}
+
+type staggeredRefreshConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	HotWindow time.Duration `yaml:"hot_window"`
+	HotPeriod time.Duration `yaml:"hot_period"`
+}
+
+type githubConfig struct {
+	AuthToken         string `yaml:"auth_token"`
+	AppID             int64  `yaml:"app_id"`
+	AppInstallationID int64  `yaml:"app_installation_id"`
+	AppPrivateKeyFile string `yaml:"app_private_key_file"`
+
+	APIURL         string `yaml:"api_url"`
+	UploadURL      string `yaml:"upload_url"`
+	CAFile         string `yaml:"ca_file"`
+	ClientCertFile string `yaml:"client_cert_file"`
+	ClientKeyFile  string `yaml:"client_key_file"`
+	ProxyURL       string `yaml:"proxy_url"`
+
+	MaxRetries     int           `yaml:"max_retries"`
+	RetryBaseDelay time.Duration `yaml:"retry_base_delay"`
+	RetryMaxDelay  time.Duration `yaml:"retry_max_delay"`
+
+	Cache          bool          `yaml:"cache"`
+	CacheDir       string        `yaml:"cache_dir"`
+	CacheMaxMemory int64         `yaml:"cache_max_memory"`
+	CacheMaxAge    time.Duration `yaml:"cache_max_age"`
+}
+
+type collectConfig struct {
+	Billing      bool          `yaml:"billing"`
+	Runners      bool          `yaml:"runners"`
+	Runs         bool          `yaml:"runs"`
+	Storage      bool          `yaml:"storage"`
+	Jobs         bool          `yaml:"jobs"`
+	RunsLookback time.Duration `yaml:"runs_lookback"`
+}
+
+func defaultConfig() config {
+	return config{
+		ListenAddress:       ":8080",
+		ShutdownDelay:       15 * time.Second,
+		RefreshPeriod:       30 * time.Minute,
+		MaxLastPushed:       35 * 24 * time.Hour,
+		RepoConcurrency:     10,
+		WorkflowConcurrency: 20,
+		StaggeredRefresh: staggeredRefreshConfig{
+			HotWindow: 24 * time.Hour,
+			HotPeriod: 5 * time.Minute,
+		},
+		GitHub: githubConfig{
+			MaxRetries:     github.DefaultRetryConfig.MaxRetries,
+			RetryBaseDelay: github.DefaultRetryConfig.BaseDelay,
+			RetryMaxDelay:  github.DefaultRetryConfig.MaxDelay,
+			Cache:          true,
+			CacheMaxMemory: github.DefaultCacheConfig.MaxMemory,
+			CacheMaxAge:    github.DefaultCacheConfig.MaxAge,
+		},
+		Collect: collectConfig{
+			RunsLookback: 24 * time.Hour,
+		},
+		HealthMaxRefreshPeriods: 3,
+	}
+}
+
+// loadConfig reads the configuration from the file given by -config if set, then applies the flags explicitly set in args.
+func loadConfig(args []string, errorHandling flag.ErrorHandling) (config, string, error) {
+	var (
+		cfg        = defaultConfig()
+		configFile string
+	)
+
+	fs := newFlagSet(&cfg, &configFile, errorHandling)
+	if err := fs.Parse(args); err != nil {
+		return config{}, "", err
+	}
+
+	if configFile == "" {
+		cfg.applyEnv()
+
+		return cfg, "", cfg.validate()
+	}
+
+	cfg = defaultConfig()
+	if err := cfg.loadFile(configFile); err != nil {
+		return config{}, configFile, err
+	}
+
+	// Parse again for the flags to take precedence over the file.
+	fs = newFlagSet(&cfg, &configFile, errorHandling)
+	if err := fs.Parse(args); err != nil {
+		return config{}, configFile, err
+	}
+
+	cfg.applyEnv()
+
+	return cfg, configFile, cfg.validate()
+}
+
+// applyEnv reads the secrets which are not set from the environment.
+func (c *config) applyEnv() {
+	if c.GitHub.AuthToken == "" {
+		c.GitHub.AuthToken = os.Getenv("GITHUB_TOKEN")
+	}
+
+	if c.WebhookSecret == "" {
+		c.WebhookSecret = os.Getenv("GITHUB_WEBHOOK_SECRET")
+	}
+
+	if c.AdminToken == "" {
+		c.AdminToken = os.Getenv("EXPORTER_ADMIN_TOKEN")
+	}
+}
+
+func (c *config) loadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid configuration file %q: %w", path, err)
+	}
+
+	return nil
+}
+
+// validate reports every invalid setting at once.
+func (c *config) validate() error {
+	var errs []error
+
+	invalid := func(field, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	if len(c.Organizations) == 0 {
+		invalid("organizations", "at least one organization is required")
+	}
+
+	seen := make(map[string]struct{}, len(c.Organizations))
+	for i, org := range c.Organizations {
+		field := fmt.Sprintf("organizations[%d]", i)
+
+		if org.Name == "" {
+			invalid(field+".name", "must not be empty")
+		}
+
+		if _, ok := seen[strings.ToLower(org.Name)]; ok {
+			invalid(field+".name", "organization %q is listed more than once", org.Name)
+		}
+
+		seen[strings.ToLower(org.Name)] = struct{}{}
+
+		if org.MaxLastPushed < 0 {
+			invalid(field+".max_last_pushed", "must not be negative")
+		}
+
+		if org.Filters != nil {
+			errs = append(errs, org.Filters.validate(field+".filters")...)
+		}
+	}
+
+	errs = append(errs, c.Filters.validate("filters")...)
+
+	if c.RefreshPeriod <= 0 {
+		invalid("refresh_period", "must be greater than zero")
+	}
+
+	if c.MaxLastPushed <= 0 {
+		invalid("max_last_pushed", "must be greater than zero")
+	}
+
+	if c.RepoConcurrency < 1 {
+		invalid("repo_concurrency", "must be greater than zero")
+	}
+
+	if c.WorkflowConcurrency < 1 {
+		invalid("workflow_concurrency", "must be greater than zero")
+	}
+
+	if c.IncrementalMaxAge < 0 {
+		invalid("incremental_max_age", "must not be negative")
+	}
+
+	if c.StaggeredRefresh.Enabled && c.StaggeredRefresh.HotPeriod <= 0 {
+		invalid("staggered_refresh.hot_period", "must be greater than zero")
+	}
+
+	if c.GitHub.AppID != 0 && c.GitHub.AppPrivateKeyFile == "" {
+		invalid("github.app_private_key_file", "is required when github.app_id is set")
+	}
+
+	if c.GitHub.CacheMaxMemory < 0 {
+		invalid("github.cache_max_memory", "must not be negative")
+	}
+
+	if c.GitHub.CacheMaxAge < 0 {
+		invalid("github.cache_max_age", "must not be negative")
+	}
+
+	if c.GitHub.MaxRetries < 0 {
+		invalid("github.max_retries", "must not be negative")
+	}
+
+	if c.Collect.Jobs && !c.Collect.Runs {
+		invalid("collect.jobs", "requires collect.runs")
+	}
+
+	if c.WebhookSecret != "" && !c.Collect.Runs {
+		invalid("webhook_secret", "requires collect.runs")
+	}
+
+	if c.Collect.Runs && c.Collect.RunsLookback <= 0 {
+		invalid("collect.runs_lookback", "must be greater than zero")
+	}
+
+	for platform, price := range c.PriceTable {
+		if price < 0 {
+			invalid("price_table."+platform, "must not be negative")
+		}
+	}
+
+	if c.HealthMaxRefreshPeriods <= 0 {
+		invalid("health_max_refresh_periods", "must be greater than zero")
+	}
+
+	return errors.Join(errs...)
+}
+
+func (f *filterConfig) validate(field string) []error {
+	_, err := f.repoFilter()
+	if err == nil {
+		return nil
+	}
+
+	var errs []error
+
+	// Prefix each of the invalid settings with the path of the filter.
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			errs = append(errs, fmt.Errorf("%s.%w", field, err))
+		}
+
+		return errs
+	}
+
+	return []error{fmt.Errorf("%s.%w", field, err)}
+}
+
+func (f *filterConfig) repoFilter() (actions.RepoFilter, error) {
+	return actions.ParseRepoFilter(actions.RepoFilterSpec{
+		Include:         f.Include,
+		Exclude:         f.Exclude,
+		RequiredTopics:  f.RequireTopics,
+		ForbiddenTopics: f.ForbidTopics,
+		Visibilities:    f.Visibility,
+		SkipArchived:    f.SkipArchived,
+		SkipForks:       f.SkipForks,
+	})
+}
+
+func (c *config) durationBuckets() []float64 {
+	if len(c.DurationBuckets) == 0 {
+		return actions.DefaultDurationBuckets
+	}
+
+	buckets := slices.Clone(c.DurationBuckets)
+	slices.Sort(buckets)
+
+	return buckets
+}
+
+func (c *config) orgNames() []string {
+	names := make([]string, len(c.Organizations))
+	for i, org := range c.Organizations {
+		names[i] = org.Name
+	}
+
+	return names
+}
+
+// orgFilter returns the filter of an organization, which replaces the global one if set.
+func (c *config) orgFilter(org orgConfig) (actions.RepoFilter, error) {
+	if org.Filters != nil {
+		return org.Filters.repoFilter()
+	}
+
+	return c.Filters.repoFilter()
+}
+
+func (c *config) orgMaxLastPushed(org orgConfig) time.Duration {
+	if org.MaxLastPushed > 0 {
+		return org.MaxLastPushed
+	}
+
+	return c.MaxLastPushed
+}
+
+// orgFetcherOpts returns the options shared by all the fetchers of an organization.
+func (c *config) orgFetcherOpts(org orgConfig) ([]actions.FetcherOpt, error) {
+	filter, err := c.orgFilter(org)
+	if err != nil {
+		return nil, err
+	}
+
+	return []actions.FetcherOpt{
+		actions.WithRepoConcurrency(c.RepoConcurrency),
+		actions.WithWorkflowConcurrency(c.WorkflowConcurrency),
+		actions.WithRepoFilter(filter),
+	}, nil
+}
+
+// priceTable merges the price table file and the inline price table, which takes precedence, over the default prices.
+func (c *config) priceTable() (actions.PriceTable, error) {
+	table := actions.DefaultPriceTable
+
+	if c.PriceTableFile != "" {
+		var err error
+
+		table, err = actions.LoadPriceTable(c.PriceTableFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return table.Merge(c.PriceTable)
+}
+
+// restartRequired lists the settings which changed from c to other, and which can not be applied without a restart.
+func (c *config) restartRequired(other *config) []string {
+	var changed []string
+
+	for name, values := range map[string][2]any{
+		"listen_address":             {c.ListenAddress, other.ListenAddress},
+		"pprof":                      {c.Pprof, other.Pprof},
+		"shutdown_delay":             {c.ShutdownDelay, other.ShutdownDelay},
+		"refresh_period":             {c.RefreshPeriod, other.RefreshPeriod},
+		"staggered_refresh":          {c.StaggeredRefresh, other.StaggeredRefresh},
+		"github":                     {c.GitHub, other.GitHub},
+		"collect":                    {c.Collect, other.Collect},
+		"duration_buckets":           {fmt.Sprint(c.DurationBuckets), fmt.Sprint(other.DurationBuckets)},
+		"webhook_secret":             {c.WebhookSecret, other.WebhookSecret},
+		"admin_token":                {c.AdminToken, other.AdminToken},
+		"state_file":                 {c.StateFile, other.StateFile},
+		"state_max_age":              {c.StateMaxAge, other.StateMaxAge},
+		"health_max_refresh_periods": {c.HealthMaxRefreshPeriods, other.HealthMaxRefreshPeriods},
+		"stale_threshold":            {c.StaleThreshold, other.StaleThreshold},
+	} {
+		if values[0] != values[1] {
+			changed = append(changed, name)
+		}
+	}
+
+	slices.Sort(changed)
+
+	return changed
+}
+
+func newFlagSet(cfg *config, configFile *string, errorHandling flag.ErrorHandling) *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], errorHandling)
+
+	fs.StringVar(configFile, "config", "", "YAML configuration file, reloaded on SIGHUP or when modified. Flags explicitly set take precedence over it")
+	fs.StringVar(&cfg.GitHub.AuthToken, "github-auth-token", cfg.GitHub.AuthToken, "GitHub auth token")
+	fs.Int64Var(&cfg.GitHub.AppID, "github-app-id", cfg.GitHub.AppID, "GitHub App ID, authenticates as an app installation instead of using a token")
+	fs.Int64Var(&cfg.GitHub.AppInstallationID, "github-app-installation-id", cfg.GitHub.AppInstallationID, "GitHub App installation ID, discovered for each organization if not set")
+	fs.StringVar(&cfg.GitHub.AppPrivateKeyFile, "github-app-private-key-file", cfg.GitHub.AppPrivateKeyFile, "Path to the GitHub App private key")
+	fs.Var(orgsValue{&cfg.Organizations}, "organization", "Organizations to monitor, comma separated")
+	fs.DurationVar(&cfg.MaxLastPushed, "max-last-pushed", cfg.MaxLastPushed, "How many time since the last push to consider a repo inactive")
+	fs.DurationVar(&cfg.RefreshPeriod, "refresh-period", cfg.RefreshPeriod, "Frequency at which usage data is refreshed")
+	fs.DurationVar(&cfg.ShutdownDelay, "shutdown-delay", cfg.ShutdownDelay, "Graceful shutdown delay")
+	fs.BoolVar(&cfg.Pprof, "pprof", cfg.Pprof, "Enable pprof endpoints")
+	fs.StringVar(&cfg.ListenAddress, "listen-address", cfg.ListenAddress, "The address to listen on for HTTP requests.")
+	fs.IntVar(&cfg.RepoConcurrency, "repo-concurrency", cfg.RepoConcurrency, "How many repositories can list their workflows concurrently")
+	fs.IntVar(&cfg.WorkflowConcurrency, "workflow-concurrency", cfg.WorkflowConcurrency, "How many workflow usage calls can be made concurrently")
+	fs.BoolVar(&cfg.StaggeredRefresh.Enabled, "staggered-refresh", cfg.StaggeredRefresh.Enabled, "Refresh the usage of each repository on its own schedule spread across the refresh period, instead of everything at once")
+	fs.DurationVar(&cfg.StaggeredRefresh.HotWindow, "staggered-hot-window", cfg.StaggeredRefresh.HotWindow, "Repositories pushed within this window are refreshed every -staggered-hot-period, requires -staggered-refresh")
+	fs.DurationVar(&cfg.StaggeredRefresh.HotPeriod, "staggered-hot-period", cfg.StaggeredRefresh.HotPeriod, "Frequency at which the usage of hot repositories is refreshed, requires -staggered-refresh")
+	fs.DurationVar(&cfg.IncrementalMaxAge, "incremental-max-age", cfg.IncrementalMaxAge, "Only fetch again the usage of repositories pushed since the last refresh, or fetched longer ago than this, disabled if 0")
+	fs.IntVar(&cfg.GitHub.MaxRetries, "github-max-retries", cfg.GitHub.MaxRetries, "How many times a failing GitHub API call is retried, 0 disables retries")
+	fs.DurationVar(&cfg.GitHub.RetryBaseDelay, "github-retry-base-delay", cfg.GitHub.RetryBaseDelay, "Delay before retrying a failing GitHub API call, doubled on each attempt")
+	fs.DurationVar(&cfg.GitHub.RetryMaxDelay, "github-retry-max-delay", cfg.GitHub.RetryMaxDelay, "Maximum delay between two attempts of a GitHub API call")
+	fs.BoolVar(&cfg.GitHub.Cache, "github-cache", cfg.GitHub.Cache, "Cache GitHub API responses and perform conditional requests")
+	fs.StringVar(&cfg.GitHub.CacheDir, "github-cache-dir", cfg.GitHub.CacheDir, "Directory where GitHub API responses are cached, in memory only if empty")
+	fs.Int64Var(&cfg.GitHub.CacheMaxMemory, "github-cache-max-memory", cfg.GitHub.CacheMaxMemory, "Maximum size in bytes of the GitHub API responses cached in memory, least recently used ones are evicted first, unbounded if 0")
+	fs.DurationVar(&cfg.GitHub.CacheMaxAge, "github-cache-max-age", cfg.GitHub.CacheMaxAge, "Cached GitHub API responses not used for this long are removed from -github-cache-dir, never if 0")
+	fs.StringVar(&cfg.GitHub.APIURL, "github-api-url", cfg.GitHub.APIURL, "GitHub Enterprise Server API URL, uses github.com if empty")
+	fs.StringVar(&cfg.GitHub.UploadURL, "github-upload-url", cfg.GitHub.UploadURL, "GitHub Enterprise Server upload URL, defaults to the API URL")
+	fs.StringVar(&cfg.GitHub.CAFile, "github-ca-file", cfg.GitHub.CAFile, "PEM bundle of additional certificate authorities to trust when talking to GitHub")
+	fs.StringVar(&cfg.GitHub.ClientCertFile, "github-client-cert-file", cfg.GitHub.ClientCertFile, "Client certificate to present to GitHub")
+	fs.StringVar(&cfg.GitHub.ClientKeyFile, "github-client-key-file", cfg.GitHub.ClientKeyFile, "Private key of the client certificate to present to GitHub")
+	fs.StringVar(&cfg.GitHub.ProxyURL, "github-proxy-url", cfg.GitHub.ProxyURL, "HTTP proxy to use to talk to GitHub, defaults to the proxy environment variables")
+	fs.Var(listValue{&cfg.Filters.Include}, "include-repos", "Only collect repositories matching one of these comma separated globs, or /regexps/")
+	fs.Var(listValue{&cfg.Filters.Exclude}, "exclude-repos", "Ignore repositories matching one of these comma separated globs, or /regexps/")
+	fs.Var(listValue{&cfg.Filters.RequireTopics}, "require-topics", "Only collect repositories having all these comma separated topics")
+	fs.Var(listValue{&cfg.Filters.ForbidTopics}, "forbid-topics", "Ignore repositories having any of these comma separated topics")
+	fs.Var(listValue{&cfg.Filters.Visibility}, "visibility", "Only collect repositories with one of these comma separated visibilities (public, private, internal)")
+	fs.BoolVar(&cfg.Filters.SkipArchived, "skip-archived", cfg.Filters.SkipArchived, "Ignore archived repositories")
+	fs.BoolVar(&cfg.Filters.SkipForks, "skip-forks", cfg.Filters.SkipForks, "Ignore forked repositories")
+	fs.BoolVar(&cfg.Collect.Billing, "collect-billing", cfg.Collect.Billing, "Collect the organization Actions billing, requires the admin:org scope or the Administration permission")
+	fs.BoolVar(&cfg.Collect.Runs, "collect-runs", cfg.Collect.Runs, "Collect workflow run counts per status and conclusion")
+	fs.BoolVar(&cfg.Collect.Runners, "collect-runners", cfg.Collect.Runners, "Collect the self-hosted runners of the organization, its runner groups and its repositories")
+	fs.BoolVar(&cfg.Collect.Storage, "collect-storage", cfg.Collect.Storage, "Collect the Actions cache and artifacts storage of each repository")
+	fs.DurationVar(&cfg.Collect.RunsLookback, "runs-lookback", cfg.Collect.RunsLookback, "How far back workflow runs are collected")
+	fs.BoolVar(&cfg.Collect.Jobs, "collect-jobs", cfg.Collect.Jobs, "Also collect the jobs of workflow runs, per job name and runner, requires -collect-runs")
+	fs.StringVar(&cfg.PriceTableFile, "price-table-file", cfg.PriceTableFile, "JSON file of per-minute prices in dollars per platform, overriding GitHub's default rates")
+	fs.Float64Var(&cfg.HealthMaxRefreshPeriods, "health-max-refresh-periods", cfg.HealthMaxRefreshPeriods, "/healthz fails when the last successful refresh is older than this many refresh periods")
+	fs.DurationVar(&cfg.StaleThreshold, "stale-threshold", cfg.StaleThreshold, "github_actions_workflow_data_stale is set when the last successful refresh is older than this, defaults to the /healthz max age")
+	fs.StringVar(&cfg.StateFile, "state-file", cfg.StateFile, "File where the last usage data is persisted, and restored from on startup")
+	fs.DurationVar(&cfg.StateMaxAge, "state-max-age", cfg.StateMaxAge, "How old restored usage data can be to be served without refreshing first, defaults to the refresh period")
+	fs.StringVar(&cfg.WebhookSecret, "webhook-secret", cfg.WebhookSecret, "Secret of the GitHub webhook, exposes /webhook to update the runs and jobs metrics in real time from workflow_run and workflow_job events if set, requires -collect-runs (or GITHUB_WEBHOOK_SECRET env)")
+	fs.StringVar(&cfg.AdminToken, "admin-token", cfg.AdminToken, "Bearer token required by the admin endpoints, exposes POST /admin/refresh if set (or EXPORTER_ADMIN_TOKEN env)")
+	fs.Var(bucketsValue{&cfg.DurationBuckets}, "duration-buckets", "Comma separated upper bounds in seconds of the queue and execution time histograms")
+
+	return fs
+}
+
+// listValue is a comma separated list flag.
+type listValue struct{ items *[]string }
+
+func (v listValue) String() string {
+	if v.items == nil {
+		return ""
+	}
+
+	return strings.Join(*v.items, ",")
+}
+
+func (v listValue) Set(s string) error {
+	*v.items = actions.SplitList(s)
+	return nil
+}
+
+// orgsValue sets the organizations from a comma separated list, without any specific setting.
+type orgsValue struct{ orgs *[]orgConfig }
+
+func (v orgsValue) String() string {
+	if v.orgs == nil {
+		return ""
+	}
+
+	names := make([]string, len(*v.orgs))
+	for i, org := range *v.orgs {
+		names[i] = org.Name
+	}
+
+	return strings.Join(names, ",")
+}
+
+func (v orgsValue) Set(s string) error {
+	var orgs []orgConfig
+	for _, name := range actions.SplitList(s) {
+		orgs = append(orgs, orgConfig{Name: name})
+	}
+
+	*v.orgs = orgs
+
+	return nil
+}
+
+type bucketsValue struct{ buckets *[]float64 }
+
+func (v bucketsValue) String() string {
+	if v.buckets == nil {
+		return ""
+	}
+
+	items := make([]string, len(*v.buckets))
+	for i, bucket := range *v.buckets {
+		items[i] = strconv.FormatFloat(bucket, 'f', -1, 64)
+	}
+
+	return strings.Join(items, ",")
+}
+
+func (v bucketsValue) Set(s string) error {
+	buckets, err := parseBuckets(s)
+	if err != nil {
+		return err
+	}
+
+	*v.buckets = buckets
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jlevesy/workflows-exporter/actions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	err := os.WriteFile(path, []byte(content), 0o600)
+	require.NoError(t, err)
+
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "env-token")
+
+	path := writeConfigFile(t, `
+refresh_period: 10m
+max_last_pushed: 240h
+organizations:
+  - name: some-org
+  - name: other-org
+    max_last_pushed: 48h
+    filters:
+      include: [infra-*]
+filters:
+  exclude: [/^legacy-/]
+  skip_archived: true
+repo_concurrency: 4
+price_table:
+  UBUNTU: 0.004
+`)
+
+	cfg, configFile, err := loadConfig(
+		[]string{"-config", path, "-repo-concurrency", "8"},
+		flag.ContinueOnError,
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, path, configFile)
+	assert.Equal(t, []string{"some-org", "other-org"}, cfg.orgNames())
+	assert.Equal(t, 10*time.Minute, cfg.RefreshPeriod)
+	// Explicit flags take precedence over the file.
+	assert.Equal(t, 8, cfg.RepoConcurrency)
+	// Unset settings keep their default.
+	assert.Equal(t, 20, cfg.WorkflowConcurrency)
+	assert.Equal(t, "env-token", cfg.GitHub.AuthToken)
+
+	assert.Equal(t, 240*time.Hour, cfg.orgMaxLastPushed(cfg.Organizations[0]))
+	assert.Equal(t, 48*time.Hour, cfg.orgMaxLastPushed(cfg.Organizations[1]))
+
+	filter, err := cfg.orgFilter(cfg.Organizations[0])
+	require.NoError(t, err)
+	require.Len(t, filter.Exclude, 1)
+	assert.True(t, filter.Exclude[0].MatchString("legacy-api"))
+	assert.True(t, filter.SkipArchived)
+
+	filter, err = cfg.orgFilter(cfg.Organizations[1])
+	require.NoError(t, err)
+	require.Len(t, filter.Include, 1)
+	assert.True(t, filter.Include[0].MatchString("infra-terraform"))
+	assert.Empty(t, filter.Exclude)
+	assert.False(t, filter.SkipArchived)
+
+	priceTable, err := cfg.priceTable()
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		actions.PriceTable{"UBUNTU": 0.004, "WINDOWS": 0.016, "MACOS": 0.08},
+		priceTable,
+	)
+}
+
+func TestLoadConfig_Errors(t *testing.T) {
+	for _, testCase := range []struct {
+		desc    string
+		content string
+		args    []string
+		wantErr string
+	}{
+		{
+			desc:    "unknown field",
+			content: "organisations: [some-org]\n",
+			wantErr: "field organisations not found",
+		},
+		{
+			desc:    "no organization",
+			content: "refresh_period: 10m\n",
+			wantErr: "organizations: at least one organization is required",
+		},
+		{
+			desc: "invalid settings",
+			content: `
+organizations:
+  - name: some-org
+  - name: some-org
+    filters:
+      visibility: [secret]
+refresh_period: 0s
+collect:
+  jobs: true
+webhook_secret: some-secret
+`,
+			wantErr: `organizations[1].name: organization "some-org" is listed more than once
+organizations[1].filters.visibility: unknown visibility "secret", must be one of public, private, internal
+refresh_period: must be greater than zero
+collect.jobs: requires collect.runs
+webhook_secret: requires collect.runs`,
+		},
+		{
+			desc:    "invalid flag",
+			content: "organizations: [{name: some-org}]\n",
+			args:    []string{"-refresh-period", "forever"},
+			wantErr: `invalid value "forever" for flag -refresh-period`,
+		},
+	} {
+		t.Run(testCase.desc, func(t *testing.T) {
+			path := writeConfigFile(t, testCase.content)
+
+			_, _, err := loadConfig(
+				append([]string{"-config", path}, testCase.args...),
+				flag.ContinueOnError,
+			)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), testCase.wantErr)
+		})
+	}
+}
+
+func TestConfig_RestartRequired(t *testing.T) {
+	var (
+		current = defaultConfig()
+		next    = defaultConfig()
+	)
+
+	next.Organizations = []orgConfig{{Name: "new-org"}}
+	next.Filters.SkipForks = true
+	next.ListenAddress = ":9090"
+	next.Collect.Runs = true
+
+	assert.Equal(t, []string{"collect", "listen_address"}, current.restartRequired(&next))
+}
@@ -4,13 +4,17 @@ import (
 	"context"
 	"errors"
 	"flag"
+	"fmt"
 	"net/http"
 	"net/http/pprof"
 	"os"
 	"os/signal"
+	"sort"
+	"strconv"
 	"syscall"
 	"time"
 
+	gogithub "github.com/google/go-github/v57/github"
 	"github.com/jlevesy/workflows-exporter/actions"
 	"github.com/jlevesy/workflows-exporter/pkg/github"
 	"github.com/prometheus/client_golang/prometheus/collectors"
@@ -23,71 +27,249 @@ import (
 func main() { os.Exit(run()) }
 
 func run() int {
-	var (
-		listenAddress   string
-		githubAuthToken string
-		organization    string
-		enablePprof     bool
-		maxLastPushed   time.Duration
-		refreshPeriod   time.Duration
-		shutdownDelay   time.Duration
-	)
-
-	flag.StringVar(&githubAuthToken, "github-auth-token", "", "GitHub auth token")
-	flag.StringVar(&organization, "organization", "", "Organization to monitor")
-	flag.DurationVar(&maxLastPushed, "max-last-pushed", 35*24*time.Hour, "How many time since the last push to consider a repo inactive")
-	flag.DurationVar(&refreshPeriod, "refresh-period", 30*time.Minute, "Frequency at which usage data is refreshed")
-	flag.DurationVar(&shutdownDelay, "shutdown-delay", 15*time.Second, "Graceful shutdown delay")
-	flag.BoolVar(&enablePprof, "pprof", false, "Enable pprof endpoints")
-	flag.StringVar(&listenAddress, "listen-address", ":8080", "The address to listen on for HTTP requests.")
-	flag.Parse()
+	cfg, configFile, err := loadConfig(os.Args[1:], flag.ExitOnError)
 
 	logger := zap.Must(zap.NewProduction())
 
+	if err != nil {
+		logger.Error("Invalid configuration, exiting", zap.Error(err))
+		return 1
+	}
+
 	logger.Info(
 		"Starting exporter",
-		zap.String("organization", organization),
-		zap.Duration("max_last_pushed", maxLastPushed),
-		zap.Duration("refresh_period", refreshPeriod),
-		zap.String("listen_address", listenAddress),
-		zap.Bool("pprof", enablePprof),
+		zap.String("config_file", configFile),
+		zap.Strings("organizations", cfg.orgNames()),
+		zap.Duration("max_last_pushed", cfg.MaxLastPushed),
+		zap.Duration("refresh_period", cfg.RefreshPeriod),
+		zap.Int("repo_concurrency", cfg.RepoConcurrency),
+		zap.Int("workflow_concurrency", cfg.WorkflowConcurrency),
+		zap.Duration("incremental_max_age", cfg.IncrementalMaxAge),
+		zap.String("listen_address", cfg.ListenAddress),
+		zap.Bool("pprof", cfg.Pprof),
+		zap.Bool("github_cache", cfg.GitHub.Cache),
+		zap.String("github_cache_dir", cfg.GitHub.CacheDir),
+		zap.String("github_api_url", cfg.GitHub.APIURL),
+		zap.String("state_file", cfg.StateFile),
+		zap.Bool("collect_billing", cfg.Collect.Billing),
+		zap.Bool("collect_runners", cfg.Collect.Runners),
+		zap.Bool("collect_runs", cfg.Collect.Runs),
+		zap.Bool("collect_storage", cfg.Collect.Storage),
+		zap.Bool("collect_jobs", cfg.Collect.Jobs),
+		zap.Duration("runs_lookback", cfg.Collect.RunsLookback),
 	)
 
-	if githubAuthToken == "" {
-		githubAuthToken = os.Getenv("GITHUB_TOKEN")
+	buckets := cfg.durationBuckets()
+
+	priceTable, err := cfg.priceTable()
+	if err != nil {
+		logger.Error("Could not load price table", zap.Error(err))
+		return 1
 	}
 
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	gh, err := github.NewClient(ctx, githubAuthToken, logger)
+	reg := prometheus.NewRegistry()
+	ghMetrics := github.NewMetrics()
+
+	transport, err := github.NewTransport(github.TransportConfig{
+		CAFile:   cfg.GitHub.CAFile,
+		CertFile: cfg.GitHub.ClientCertFile,
+		KeyFile:  cfg.GitHub.ClientKeyFile,
+		ProxyURL: cfg.GitHub.ProxyURL,
+	})
+	if err != nil {
+		logger.Error("Could not setup github transport", zap.Error(err))
+		return 1
+	}
+
+	ghOpts := []github.ClientOpt{
+		github.WithRetry(github.RetryConfig{
+			MaxRetries: cfg.GitHub.MaxRetries,
+			BaseDelay:  cfg.GitHub.RetryBaseDelay,
+			MaxDelay:   cfg.GitHub.RetryMaxDelay,
+		}),
+		github.WithMetrics(ghMetrics),
+		github.WithTransport(transport),
+	}
+
+	if cfg.GitHub.APIURL != "" {
+		ghOpts = append(ghOpts, github.WithEnterpriseURLs(cfg.GitHub.APIURL, cfg.GitHub.UploadURL))
+	}
+
+	if cfg.GitHub.Cache {
+		cache, err := newCache(cfg.GitHub.CacheDir, github.CacheConfig{
+			MaxMemory: cfg.GitHub.CacheMaxMemory,
+			MaxAge:    cfg.GitHub.CacheMaxAge,
+		}, logger)
+		if err != nil {
+			logger.Error("Could not setup github cache", zap.Error(err))
+			return 1
+		}
+
+		ghOpts = append(ghOpts, github.WithCache(cache))
+	}
+
+	auth := github.AuthConfig{
+		Token:          cfg.GitHub.AuthToken,
+		InstallationID: cfg.GitHub.AppInstallationID,
+	}
+
+	if cfg.GitHub.AppID != 0 {
+		privateKey, err := github.LoadPrivateKey(cfg.GitHub.AppPrivateKeyFile)
+		if err != nil {
+			logger.Error("Could not load GitHub App private key", zap.Error(err))
+			return 1
+		}
+
+		auth.App = github.AppConfig{AppID: cfg.GitHub.AppID, PrivateKey: privateKey}
+	}
+
+	clients, err := github.NewOrgClients(ctx, auth, cfg.orgNames(), logger, ghOpts...)
 	if err != nil {
 		logger.Error("Could not setup github client", zap.Error(err))
 		return 1
 	}
 
-	fetcher := actions.NewOrgUsageFetcher(
-		maxLastPushed,
-		organization,
-		gh,
+	fetcher, usageFetchers, err := newUsageFetcher(&cfg, clients, nil, logger)
+	if err != nil {
+		logger.Error("Could not setup usage fetcher", zap.Error(err))
+		return 1
+	}
+
+	healthMaxAge := time.Duration(cfg.HealthMaxRefreshPeriods * float64(cfg.RefreshPeriod))
+
+	staleThreshold := cfg.StaleThreshold
+	if staleThreshold == 0 {
+		staleThreshold = healthMaxAge
+	}
+
+	usageCollectorOpts := []actions.UsageCollectorOpt{
+		actions.WithPriceTable(priceTable),
+		actions.WithStaleThreshold(staleThreshold),
+	}
+
+	if cfg.StateFile != "" {
+		usageCollectorOpts = append(usageCollectorOpts, actions.WithStateFile(cfg.StateFile, cfg.StateMaxAge))
+	}
+
+	if cfg.StaggeredRefresh.Enabled {
+		usageCollectorOpts = append(
+			usageCollectorOpts,
+			actions.WithStaggeredRefresh(actions.StaggeredRefreshConfig{
+				HotWindow:   cfg.StaggeredRefresh.HotWindow,
+				HotPeriod:   cfg.StaggeredRefresh.HotPeriod,
+				Concurrency: cfg.RepoConcurrency,
+			}),
+		)
+	}
+
+	usageCollector := actions.NewUsageCollector(
+		fetcher,
 		logger,
+		cfg.RefreshPeriod,
+		usageCollectorOpts...,
 	)
 
-	usageCollector := actions.NewUsageCollector(fetcher, logger, refreshPeriod)
-
 	defer usageCollector.Close()
 
-	reg := prometheus.NewRegistry()
-
 	reg.MustRegister(
 		collectors.NewGoCollector(),
 		usageCollector,
+		ghMetrics,
 	)
 
+	var runsCollector *actions.RunsCollector
+	if cfg.Collect.Runs {
+		runsFetcher, err := newRunsFetcher(&cfg, clients, logger)
+		if err != nil {
+			logger.Error("Could not setup runs fetcher", zap.Error(err))
+			return 1
+		}
+
+		runsCollectorOpts := []actions.RunsCollectorOpt{
+			actions.WithDurationBuckets(buckets),
+			actions.WithRunsLookback(cfg.Collect.RunsLookback),
+		}
+		if cfg.Collect.Jobs {
+			runsCollectorOpts = append(runsCollectorOpts, actions.WithJobs())
+		}
+
+		runsCollector = actions.NewRunsCollector(
+			runsFetcher,
+			logger,
+			cfg.RefreshPeriod,
+			runsCollectorOpts...,
+		)
+
+		defer runsCollector.Close()
+
+		reg.MustRegister(runsCollector)
+	}
+
+	var billingCollector *actions.BillingCollector
+	if cfg.Collect.Billing {
+		billingCollector = actions.NewBillingCollector(
+			newBillingFetcher(&cfg, clients, logger),
+			logger,
+			cfg.RefreshPeriod,
+		)
+
+		defer billingCollector.Close()
+
+		reg.MustRegister(billingCollector)
+	}
+
+	var storageCollector *actions.StorageCollector
+	if cfg.Collect.Storage {
+		storageFetcher, err := newStorageFetcher(&cfg, clients, logger)
+		if err != nil {
+			logger.Error("Could not setup storage fetcher", zap.Error(err))
+			return 1
+		}
+
+		storageCollector = actions.NewStorageCollector(
+			storageFetcher,
+			logger,
+			cfg.RefreshPeriod,
+		)
+
+		defer storageCollector.Close()
+
+		reg.MustRegister(storageCollector)
+	}
+
+	var runnersCollector *actions.RunnersCollector
+	if cfg.Collect.Runners {
+		fleetFetcher, err := newRunnerFleetFetcher(&cfg, clients, logger)
+		if err != nil {
+			logger.Error("Could not setup runners fetcher", zap.Error(err))
+			return 1
+		}
+
+		runnersCollector = actions.NewRunnersCollector(
+			fleetFetcher,
+			logger,
+			cfg.RefreshPeriod,
+		)
+
+		defer runnersCollector.Close()
+
+		reg.MustRegister(runnersCollector)
+	}
+
+	var webhookReceiver *actions.WebhookReceiver
+	if cfg.WebhookSecret != "" {
+		webhookReceiver = actions.NewWebhookReceiver([]byte(cfg.WebhookSecret), runsCollector, logger)
+
+		reg.MustRegister(webhookReceiver)
+	}
+
 	var (
 		mux http.ServeMux
 		srv = http.Server{
-			Addr:    listenAddress,
+			Addr:    cfg.ListenAddress,
 			Handler: &mux,
 		}
 	)
@@ -95,7 +277,22 @@ func run() int {
 	// Expose /metrics HTTP endpoint using the created custom registry.
 	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg}))
 
-	if enablePprof {
+	mux.Handle("/readyz", usageCollector.ReadyHandler())
+	mux.Handle(
+		"/healthz",
+		usageCollector.HealthHandler(healthMaxAge),
+	)
+
+	// Events update the runs collector in real time, its refreshes reconcile the events that could be missed.
+	if webhookReceiver != nil {
+		mux.Handle("/webhook", webhookReceiver)
+	}
+
+	if cfg.AdminToken != "" {
+		mux.Handle("/admin/refresh", usageCollector.RefreshHandler(cfg.AdminToken))
+	}
+
+	if cfg.Pprof {
 		mux.HandleFunc("/debug/pprof/", pprof.Index)
 		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
 		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
@@ -103,12 +300,103 @@ func run() int {
 		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
 	}
 
+	if configFile != "" {
+		reloader := configReloader{
+			path:    configFile,
+			args:    os.Args[1:],
+			started: cfg,
+			logger:  logger,
+			apply: func(ctx context.Context, newCfg *config) error {
+				priceTable, err := newCfg.priceTable()
+				if err != nil {
+					return err
+				}
+
+				var newOrgs []string
+				for _, org := range newCfg.orgNames() {
+					if _, ok := clients[org]; !ok {
+						newOrgs = append(newOrgs, org)
+					}
+				}
+
+				if len(newOrgs) > 0 {
+					newClients, err := github.NewOrgClients(ctx, auth, newOrgs, logger, ghOpts...)
+					if err != nil {
+						return err
+					}
+
+					for org, client := range newClients {
+						clients[org] = client
+					}
+				}
+
+				// Every fetcher is built before any is replaced, so that an error keeps the current configuration.
+				fetcher, newUsageFetchers, err := newUsageFetcher(newCfg, clients, usageFetchers, logger)
+				if err != nil {
+					return err
+				}
+
+				var setFetchers []func()
+
+				if runsCollector != nil {
+					runsFetcher, err := newRunsFetcher(newCfg, clients, logger)
+					if err != nil {
+						return err
+					}
+
+					setFetchers = append(setFetchers, func() { runsCollector.SetFetcher(runsFetcher) })
+				}
+
+				if billingCollector != nil {
+					billingFetcher := newBillingFetcher(newCfg, clients, logger)
+
+					setFetchers = append(setFetchers, func() { billingCollector.SetFetcher(billingFetcher) })
+				}
+
+				if storageCollector != nil {
+					storageFetcher, err := newStorageFetcher(newCfg, clients, logger)
+					if err != nil {
+						return err
+					}
+
+					setFetchers = append(setFetchers, func() { storageCollector.SetFetcher(storageFetcher) })
+				}
+
+				if runnersCollector != nil {
+					fleetFetcher, err := newRunnerFleetFetcher(newCfg, clients, logger)
+					if err != nil {
+						return err
+					}
+
+					setFetchers = append(setFetchers, func() { runnersCollector.SetFetcher(fleetFetcher) })
+				}
+
+				usageFetchers = newUsageFetchers
+				usageCollector.SetFetcher(fetcher)
+				usageCollector.SetPriceTable(priceTable)
+
+				// The other collectors apply the new settings on their next refresh.
+				for _, setFetcher := range setFetchers {
+					setFetcher()
+				}
+
+				// Apply the new settings right away, the current data is served until the refresh is done.
+				// A refresh in flight uses the previous fetcher, this one starts once it is done.
+				usageCollector.Refresh()
+
+				return nil
+			},
+		}
+
+		go reloader.run(ctx, configPollPeriod)
+	}
+
 	go func() {
 		<-ctx.Done()
 
 		logger.Info("Received a signal, exiting")
 
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownDelay)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownDelay)
 		defer cancel()
 
 		if err := srv.Shutdown(shutdownCtx); err != nil {
@@ -130,3 +418,146 @@ func run() int {
 
 	return 0
 }
+
+func newCache(dir string, config github.CacheConfig, logger *zap.Logger) (github.Cache, error) {
+	if dir == "" {
+		return github.NewMemoryCache(config.MaxMemory), nil
+	}
+
+	return github.NewDiskCache(dir, config, logger)
+}
+
+// newUsageFetcher builds the usage fetcher of each organization. Those of previous, if any, pass their incremental
+// refresh cache over to the new ones.
+func newUsageFetcher(cfg *config, clients map[string]*gogithub.Client, previous map[string]*actions.OrgUsageFetcher, logger *zap.Logger) (*actions.MultiOrgFetcher[actions.Usage], map[string]*actions.OrgUsageFetcher, error) {
+	var (
+		orgFetchers = make(map[string]*actions.OrgUsageFetcher, len(cfg.Organizations))
+		fetchers    = make(map[string]actions.WorkflowUsageFetcher, len(cfg.Organizations))
+	)
+
+	for _, org := range cfg.Organizations {
+		fetcherOpts, err := cfg.orgFetcherOpts(org)
+		if err != nil {
+			return nil, nil, fmt.Errorf("organization %q: %w", org.Name, err)
+		}
+
+		if cfg.IncrementalMaxAge > 0 {
+			fetcherOpts = append(fetcherOpts, actions.WithIncrementalRefresh(cfg.IncrementalMaxAge))
+		}
+
+		fetcher := actions.NewOrgUsageFetcher(
+			cfg.orgMaxLastPushed(org),
+			org.Name,
+			clients[org.Name],
+			logger,
+			fetcherOpts...,
+		)
+
+		fetcher.InheritCache(previous[org.Name])
+
+		orgFetchers[org.Name] = fetcher
+		fetchers[org.Name] = fetcher
+	}
+
+	return actions.NewMultiOrgUsageFetcher(fetchers, logger), orgFetchers, nil
+}
+
+func newRunsFetcher(cfg *config, clients map[string]*gogithub.Client, logger *zap.Logger) (*actions.MultiOrgFetcher[actions.Runs], error) {
+	fetchers := make(map[string]actions.WorkflowRunsFetcher, len(cfg.Organizations))
+
+	for _, org := range cfg.Organizations {
+		fetcherOpts, err := cfg.orgFetcherOpts(org)
+		if err != nil {
+			return nil, fmt.Errorf("organization %q: %w", org.Name, err)
+		}
+
+		if cfg.Collect.Jobs {
+			fetcherOpts = append(fetcherOpts, actions.WithWorkflowJobs())
+		}
+
+		fetchers[org.Name] = actions.NewOrgRunsFetcher(
+			cfg.Collect.RunsLookback,
+			cfg.orgMaxLastPushed(org),
+			org.Name,
+			clients[org.Name],
+			logger,
+			fetcherOpts...,
+		)
+	}
+
+	return actions.NewMultiOrgRunsFetcher(fetchers, logger), nil
+}
+
+func newBillingFetcher(cfg *config, clients map[string]*gogithub.Client, logger *zap.Logger) *actions.MultiOrgFetcher[actions.Billing] {
+	fetchers := make(map[string]actions.BillingFetcher, len(cfg.Organizations))
+
+	for _, org := range cfg.orgNames() {
+		fetchers[org] = actions.NewOrgBillingFetcher(org, clients[org])
+	}
+
+	return actions.NewMultiOrgBillingFetcher(fetchers, logger)
+}
+
+func newStorageFetcher(cfg *config, clients map[string]*gogithub.Client, logger *zap.Logger) (*actions.MultiOrgFetcher[actions.Storage], error) {
+	fetchers := make(map[string]actions.StorageFetcher, len(cfg.Organizations))
+
+	for _, org := range cfg.Organizations {
+		fetcherOpts, err := cfg.orgFetcherOpts(org)
+		if err != nil {
+			return nil, fmt.Errorf("organization %q: %w", org.Name, err)
+		}
+
+		fetchers[org.Name] = actions.NewOrgStorageFetcher(
+			cfg.orgMaxLastPushed(org),
+			org.Name,
+			clients[org.Name],
+			logger,
+			fetcherOpts...,
+		)
+	}
+
+	return actions.NewMultiOrgStorageFetcher(fetchers, logger), nil
+}
+
+func newRunnerFleetFetcher(cfg *config, clients map[string]*gogithub.Client, logger *zap.Logger) (*actions.MultiOrgFetcher[actions.RunnerFleet], error) {
+	fetchers := make(map[string]actions.RunnerFleetFetcher, len(cfg.Organizations))
+
+	for _, org := range cfg.Organizations {
+		fetcherOpts, err := cfg.orgFetcherOpts(org)
+		if err != nil {
+			return nil, fmt.Errorf("organization %q: %w", org.Name, err)
+		}
+
+		fetchers[org.Name] = actions.NewOrgRunnerFleetFetcher(
+			cfg.orgMaxLastPushed(org),
+			org.Name,
+			clients[org.Name],
+			logger,
+			fetcherOpts...,
+		)
+	}
+
+	return actions.NewMultiOrgRunnerFleetFetcher(fetchers, logger), nil
+}
+
+func parseBuckets(v string) ([]float64, error) {
+	items := actions.SplitList(v)
+	if len(items) == 0 {
+		return actions.DefaultDurationBuckets, nil
+	}
+
+	buckets := make([]float64, 0, len(items))
+
+	for _, item := range items {
+		bucket, err := strconv.ParseFloat(item, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		buckets = append(buckets, bucket)
+	}
+
+	sort.Float64s(buckets)
+
+	return buckets, nil
+}
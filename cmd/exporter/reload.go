@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const configPollPeriod = 10 * time.Second
+
+// configReloader reloads the configuration file on SIGHUP or when it changes on disk.
+type configReloader struct {
+	path    string
+	args    []string
+	started config
+	logger  *zap.Logger
+	apply   func(ctx context.Context, cfg *config) error
+
+	modTime time.Time
+}
+
+func (r *configReloader) run(ctx context.Context, pollPeriod time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	r.modTime = r.fileModTime()
+
+	ticker := time.NewTicker(pollPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.reload(ctx, "signal")
+		case <-ticker.C:
+			modTime := r.fileModTime()
+			if modTime.IsZero() || modTime.Equal(r.modTime) {
+				continue
+			}
+
+			r.reload(ctx, "file_changed")
+		}
+	}
+}
+
+func (r *configReloader) reload(ctx context.Context, reason string) {
+	r.modTime = r.fileModTime()
+
+	// Flags given on the command line keep taking precedence over the file.
+	cfg, _, err := loadConfig(r.args, flag.ContinueOnError)
+	if err != nil {
+		r.logger.Error("Could not reload the configuration, keeping the current one", zap.String("reason", reason), zap.Error(err))
+		return
+	}
+
+	if ignored := r.started.restartRequired(&cfg); len(ignored) > 0 {
+		r.logger.Warn("Some settings changed but require a restart to be applied", zap.Strings("settings", ignored))
+	}
+
+	if err := r.apply(ctx, &cfg); err != nil {
+		r.logger.Error("Could not apply the new configuration, keeping the current one", zap.String("reason", reason), zap.Error(err))
+		return
+	}
+
+	r.logger.Info("Configuration reloaded", zap.String("reason", reason), zap.Strings("organizations", cfg.orgNames()))
+}
+
+func (r *configReloader) fileModTime() time.Time {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
@@ -5,7 +5,7 @@ import (
 	"flag"
 	"os"
 	"os/signal"
-	"sort"
+	"strings"
 	"syscall"
 	"time"
 
@@ -21,62 +21,212 @@ func run() int {
 		githubAuthToken string
 		organization    string
 		maxLastPushed   time.Duration
+
+		repoConcurrency     int
+		workflowConcurrency int
+
+		retryConfig = github.DefaultRetryConfig
+		cacheDir    string
+
+		appID             int64
+		appInstallationID int64
+		appPrivateKeyFile string
+
+		apiURL          string
+		uploadURL       string
+		transportConfig github.TransportConfig
+
+		includeRepos    string
+		excludeRepos    string
+		requiredTopics  string
+		forbiddenTopics string
+		visibilities    string
+		skipArchived    bool
+		skipForks       bool
+
+		format    string
+		sortOrder string
+		platforms string
+		groupBy   string
+		top       int
 	)
 
 	flag.StringVar(&githubAuthToken, "github-auth-token", "", "GitHub auth token")
-	flag.StringVar(&organization, "organization", "", "organization")
+	flag.Int64Var(&appID, "github-app-id", 0, "GitHub App ID, authenticates as an app installation instead of using a token")
+	flag.Int64Var(&appInstallationID, "github-app-installation-id", 0, "GitHub App installation ID, discovered for each organization if not set")
+	flag.StringVar(&appPrivateKeyFile, "github-app-private-key-file", "", "Path to the GitHub App private key")
+	flag.StringVar(&organization, "organization", "", "Organizations to report, comma separated")
 	flag.DurationVar(&maxLastPushed, "max-last-pushed", 30*24*time.Hour, "How many time since the last push to consider a repo inactive")
+	flag.IntVar(&repoConcurrency, "repo-concurrency", 10, "How many repositories can list their workflows concurrently")
+	flag.IntVar(&workflowConcurrency, "workflow-concurrency", 20, "How many workflow usage calls can be made concurrently")
+	flag.IntVar(&retryConfig.MaxRetries, "github-max-retries", retryConfig.MaxRetries, "How many times a failing GitHub API call is retried, 0 disables retries")
+	flag.DurationVar(&retryConfig.BaseDelay, "github-retry-base-delay", retryConfig.BaseDelay, "Delay before retrying a failing GitHub API call, doubled on each attempt")
+	flag.DurationVar(&retryConfig.MaxDelay, "github-retry-max-delay", retryConfig.MaxDelay, "Maximum delay between two attempts of a GitHub API call")
+	flag.StringVar(&cacheDir, "github-cache-dir", "", "Directory where GitHub API responses are cached between runs, disabled if empty")
+	flag.StringVar(&apiURL, "github-api-url", "", "GitHub Enterprise Server API URL, uses github.com if empty")
+	flag.StringVar(&uploadURL, "github-upload-url", "", "GitHub Enterprise Server upload URL, defaults to the API URL")
+	flag.StringVar(&transportConfig.CAFile, "github-ca-file", "", "PEM bundle of additional certificate authorities to trust when talking to GitHub")
+	flag.StringVar(&transportConfig.CertFile, "github-client-cert-file", "", "Client certificate to present to GitHub")
+	flag.StringVar(&transportConfig.KeyFile, "github-client-key-file", "", "Private key of the client certificate to present to GitHub")
+	flag.StringVar(&transportConfig.ProxyURL, "github-proxy-url", "", "HTTP proxy to use to talk to GitHub, defaults to the proxy environment variables")
+	flag.StringVar(&includeRepos, "include-repos", "", "Only collect repositories matching one of these comma separated globs, or /regexps/")
+	flag.StringVar(&excludeRepos, "exclude-repos", "", "Ignore repositories matching one of these comma separated globs, or /regexps/")
+	flag.StringVar(&requiredTopics, "require-topics", "", "Only collect repositories having all these comma separated topics")
+	flag.StringVar(&forbiddenTopics, "forbid-topics", "", "Ignore repositories having any of these comma separated topics")
+	flag.StringVar(&visibilities, "visibility", "", "Only collect repositories with one of these comma separated visibilities (public, private, internal)")
+	flag.BoolVar(&skipArchived, "skip-archived", false, "Ignore archived repositories")
+	flag.BoolVar(&skipForks, "skip-forks", false, "Ignore forked repositories")
+	flag.StringVar(&format, "format", formatTable, "Output format, one of "+strings.Join(formats, ", "))
+	flag.StringVar(&sortOrder, "sort", sortByRepo, "Sort order of the output, either repo or billable-time, which lists the longest billable time first")
+	flag.StringVar(&platforms, "platform", "", "Only print the billable time of these comma separated platforms (UBUNTU, WINDOWS, MACOS...)")
+	flag.StringVar(&groupBy, "group-by", "", "Print a report summing the billable time per these comma separated columns ("+strings.Join(groupColumns, ", ")+"), from the largest total")
+	flag.IntVar(&top, "top", 0, "Only print the first entries of the report, all of them if 0, requires -group-by")
 	flag.Parse()
 
 	logger := zap.Must(zap.NewDevelopment())
 
-	if organization == "" {
-		logger.Error("You must provide an organization, exiting")
+	if err := validChoice(format, formats); err != nil {
+		logger.Error("Invalid output format", zap.Error(err))
+		return 1
+	}
+
+	if err := validChoice(sortOrder, sortOrders); err != nil {
+		logger.Error("Invalid sort order", zap.Error(err))
+		return 1
+	}
+
+	reportColumns, err := parseGroupBy(groupBy)
+	if err != nil {
+		logger.Error("Invalid report columns", zap.Error(err))
+		return 1
+	}
+
+	if top < 0 || (top > 0 && len(reportColumns) == 0) {
+		logger.Error("-top must be positive, and requires -group-by")
+		return 1
+	}
+
+	organizations := actions.SplitList(organization)
+
+	if len(organizations) == 0 {
+		logger.Error("You must provide at least one organization, exiting")
+		return 1
+	}
+
+	if repoConcurrency < 1 || workflowConcurrency < 1 {
+		logger.Error("Concurrency settings must be greater than zero, exiting")
+		return 1
+	}
+
+	repoFilter, err := actions.ParseRepoFilter(actions.RepoFilterSpec{
+		Include:         actions.SplitList(includeRepos),
+		Exclude:         actions.SplitList(excludeRepos),
+		RequiredTopics:  actions.SplitList(requiredTopics),
+		ForbiddenTopics: actions.SplitList(forbiddenTopics),
+		Visibilities:    actions.SplitList(visibilities),
+		SkipArchived:    skipArchived,
+		SkipForks:       skipForks,
+	})
+	if err != nil {
+		logger.Error("Invalid repository filters", zap.Error(err))
 		return 1
 	}
 
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	gh, err := github.NewClient(ctx, githubAuthToken, logger)
+	transport, err := github.NewTransport(transportConfig)
+	if err != nil {
+		logger.Error("Could not setup github transport", zap.Error(err))
+		return 1
+	}
+
+	ghOpts := []github.ClientOpt{
+		github.WithRetry(retryConfig),
+		github.WithTransport(transport),
+	}
+
+	if apiURL != "" {
+		ghOpts = append(ghOpts, github.WithEnterpriseURLs(apiURL, uploadURL))
+	}
+
+	if cacheDir != "" {
+		cache, err := github.NewDiskCache(cacheDir, github.DefaultCacheConfig, logger)
+		if err != nil {
+			logger.Error("Could not setup github cache", zap.Error(err))
+			return 1
+		}
+
+		ghOpts = append(ghOpts, github.WithCache(cache))
+	}
+
+	auth := github.AuthConfig{
+		Token:          githubAuthToken,
+		InstallationID: appInstallationID,
+	}
+
+	if appID != 0 {
+		privateKey, err := github.LoadPrivateKey(appPrivateKeyFile)
+		if err != nil {
+			logger.Error("Could not load GitHub App private key", zap.Error(err))
+			return 1
+		}
+
+		auth.App = github.AppConfig{AppID: appID, PrivateKey: privateKey}
+	}
+
+	clients, err := github.NewOrgClients(ctx, auth, organizations, logger, ghOpts...)
 	if err != nil {
 		logger.Error("Could not setup github client", zap.Error(err))
 		return 1
 	}
 
-	fetcher := actions.NewOrgUsageFetcher(
-		maxLastPushed,
-		organization,
-		gh,
-		logger,
-	)
+	fetchers := make(map[string]actions.WorkflowUsageFetcher, len(organizations))
+	for _, org := range organizations {
+		fetchers[org] = actions.NewOrgUsageFetcher(
+			maxLastPushed,
+			org,
+			clients[org],
+			logger,
+			actions.WithRepoConcurrency(repoConcurrency),
+			actions.WithWorkflowConcurrency(workflowConcurrency),
+			actions.WithRepoFilter(repoFilter),
+		)
+	}
+
+	fetcher := actions.NewMultiOrgUsageFetcher(fetchers, logger)
 
 	usage, err := fetcher.Fetch(ctx)
 	if err != nil {
 		logger.Error(
 			"Unable to retrieve usage information",
-			zap.String("org", organization),
+			zap.Strings("organizations", organizations),
 			zap.Error(err),
 		)
 
 		return 1
 	}
 
-	sort.Slice(usage.Workflows, func(i, j int) bool {
-		return usage.Workflows[i].Repo < usage.Workflows[j].Repo
-	})
-
 	logger.Info("Reporting stats", zap.Int64("active repos", usage.ActiveRepos))
 
-	for _, workflowUsage := range usage.Workflows {
-		logger.Info(
-			"Got usage stats",
-			zap.String("owner", workflowUsage.Owner),
-			zap.String("repo", workflowUsage.Repo),
-			zap.String("workflow", workflowUsage.Workflow),
-			zap.Int64("worfkow_id", workflowUsage.ID),
-			zap.Any("billable_time", workflowUsage.BillableTime),
-		)
+	rows := usageRows(usage, actions.SplitList(platforms))
+
+	if len(reportColumns) > 0 {
+		report := reportRows(rows, reportColumns, top)
+
+		if err := writeReportRows(os.Stdout, format, reportColumns, report); err != nil {
+			logger.Error("Could not write report", zap.Error(err))
+			return 1
+		}
+
+		return 0
+	}
+
+	sortUsageRows(rows, sortOrder)
+
+	if err := writeUsageRows(os.Stdout, format, rows); err != nil {
+		logger.Error("Could not write usage", zap.Error(err))
+		return 1
 	}
 
 	return 0
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jlevesy/workflows-exporter/actions"
+)
+
+const (
+	formatTable  = "table"
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+
+	sortByRepo         = "repo"
+	sortByBillableTime = "billable-time"
+)
+
+var (
+	formats    = []string{formatTable, formatJSON, formatNDJSON, formatCSV}
+	sortOrders = []string{sortByRepo, sortByBillableTime}
+)
+
+// usageRow is the billable time of a workflow on a single platform.
+type usageRow struct {
+	Owner        string        `json:"owner"`
+	Repo         string        `json:"repo"`
+	Workflow     string        `json:"workflow"`
+	ID           int64         `json:"id"`
+	Platform     string        `json:"platform"`
+	BillableTime time.Duration `json:"-"`
+}
+
+func (r usageRow) MarshalJSON() ([]byte, error) {
+	type row usageRow
+
+	return json.Marshal(struct {
+		row
+		BillableTimeSeconds float64 `json:"billable_time_seconds"`
+	}{
+		row:                 row(r),
+		BillableTimeSeconds: r.BillableTime.Seconds(),
+	})
+}
+
+var (
+	usageColumns = []string{"owner", "repo", "workflow", "id", "platform", "billable_time_seconds"}
+	usageHeaders = []string{"OWNER", "REPO", "WORKFLOW", "ID", "PLATFORM", "BILLABLE TIME"}
+)
+
+func (r usageRow) values() []string {
+	return []string{
+		r.Owner,
+		r.Repo,
+		r.Workflow,
+		strconv.FormatInt(r.ID, 10),
+		r.Platform,
+		strconv.FormatFloat(r.BillableTime.Seconds(), 'f', -1, 64),
+	}
+}
+
+func (r usageRow) display() []string {
+	return []string{
+		r.Owner,
+		r.Repo,
+		r.Workflow,
+		strconv.FormatInt(r.ID, 10),
+		r.Platform,
+		r.BillableTime.String(),
+	}
+}
+
+// column returns the value of a column which rows can be grouped by.
+func (r usageRow) column(name string) string {
+	switch name {
+	case columnOwner:
+		return r.Owner
+	case columnRepo:
+		return r.Repo
+	case columnWorkflow:
+		return r.Workflow
+	case columnPlatform:
+		return r.Platform
+	default:
+		return ""
+	}
+}
+
+// usageRows flattens the usage to a row per workflow and platform, keeping only the given platforms if any.
+func usageRows(usage *actions.Usage, platforms []string) []usageRow {
+	var rows []usageRow
+
+	for _, workflow := range usage.Workflows {
+		for platform, billableTime := range workflow.BillableTime {
+			if len(platforms) > 0 && !slices.ContainsFunc(platforms, func(p string) bool { return strings.EqualFold(p, platform) }) {
+				continue
+			}
+
+			rows = append(rows, usageRow{
+				Owner:        workflow.Owner,
+				Repo:         workflow.Repo,
+				Workflow:     workflow.Workflow,
+				ID:           workflow.ID,
+				Platform:     platform,
+				BillableTime: billableTime,
+			})
+		}
+	}
+
+	return rows
+}
+
+func sortUsageRows(rows []usageRow, order string) {
+	byRepo := func(a, b usageRow) bool {
+		switch {
+		case a.Owner != b.Owner:
+			return a.Owner < b.Owner
+		case a.Repo != b.Repo:
+			return a.Repo < b.Repo
+		case a.Workflow != b.Workflow:
+			return a.Workflow < b.Workflow
+		case a.ID != b.ID:
+			return a.ID < b.ID
+		default:
+			return a.Platform < b.Platform
+		}
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		if order == sortByBillableTime && rows[i].BillableTime != rows[j].BillableTime {
+			return rows[i].BillableTime > rows[j].BillableTime
+		}
+
+		return byRepo(rows[i], rows[j])
+	})
+}
+
+// record is a line of output, encoded to JSON as is.
+type record interface {
+	// values are written to CSV, in the order of the columns.
+	values() []string
+	// display is written to the human table, in the order of the headers.
+	display() []string
+}
+
+func writeUsageRows(w io.Writer, format string, rows []usageRow) error {
+	records := make([]record, len(rows))
+	for i, row := range rows {
+		records[i] = row
+	}
+
+	return writeRecords(w, format, usageColumns, usageHeaders, records)
+}
+
+func writeRecords(w io.Writer, format string, columns, headers []string, records []record) error {
+	switch format {
+	case formatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		if records == nil {
+			records = []record{}
+		}
+
+		return encoder.Encode(records)
+	case formatNDJSON:
+		encoder := json.NewEncoder(w)
+
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case formatCSV:
+		writer := csv.NewWriter(w)
+
+		if err := writer.Write(columns); err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			if err := writer.Write(record.values()); err != nil {
+				return err
+			}
+		}
+
+		writer.Flush()
+
+		return writer.Error()
+	case formatTable:
+		writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+		fmt.Fprintln(writer, strings.Join(headers, "\t"))
+
+		for _, record := range records {
+			fmt.Fprintln(writer, strings.Join(record.display(), "\t"))
+		}
+
+		return writer.Flush()
+	default:
+		return fmt.Errorf("unsupported format %q, must be one of %s", format, strings.Join(formats, ", "))
+	}
+}
+
+func validChoice(value string, choices []string) error {
+	if !slices.Contains(choices, value) {
+		return fmt.Errorf("unsupported value %q, must be one of %s", value, strings.Join(choices, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/jlevesy/workflows-exporter/actions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testUsage = actions.Usage{
+	ActiveRepos: 2,
+	Workflows: []actions.WorkflowUsage{
+		{
+			Owner:    "some-org",
+			Repo:     "web",
+			Workflow: "ci",
+			ID:       2,
+			BillableTime: map[string]time.Duration{
+				"UBUNTU": 90 * time.Second,
+			},
+		},
+		{
+			Owner:    "some-org",
+			Repo:     "api",
+			Workflow: "release, nightly",
+			ID:       1,
+			BillableTime: map[string]time.Duration{
+				"UBUNTU": 30 * time.Second,
+				"MACOS":  10 * time.Minute,
+			},
+		},
+	},
+}
+
+func TestWriteUsageRows(t *testing.T) {
+	for _, testCase := range []struct {
+		desc      string
+		format    string
+		sortOrder string
+		want      string
+	}{
+		{
+			desc:      "table sorted by repo",
+			format:    formatTable,
+			sortOrder: sortByRepo,
+			want: `OWNER     REPO  WORKFLOW          ID  PLATFORM  BILLABLE TIME
+some-org  api   release, nightly  1   MACOS     10m0s
+some-org  api   release, nightly  1   UBUNTU    30s
+some-org  web   ci                2   UBUNTU    1m30s
+`,
+		},
+		{
+			desc:      "csv sorted by billable time",
+			format:    formatCSV,
+			sortOrder: sortByBillableTime,
+			want: `owner,repo,workflow,id,platform,billable_time_seconds
+some-org,api,"release, nightly",1,MACOS,600
+some-org,web,ci,2,UBUNTU,90
+some-org,api,"release, nightly",1,UBUNTU,30
+`,
+		},
+		{
+			desc:      "ndjson",
+			format:    formatNDJSON,
+			sortOrder: sortByRepo,
+			want: `{"owner":"some-org","repo":"api","workflow":"release, nightly","id":1,"platform":"MACOS","billable_time_seconds":600}
+{"owner":"some-org","repo":"api","workflow":"release, nightly","id":1,"platform":"UBUNTU","billable_time_seconds":30}
+{"owner":"some-org","repo":"web","workflow":"ci","id":2,"platform":"UBUNTU","billable_time_seconds":90}
+`,
+		},
+		{
+			desc:      "json",
+			format:    formatJSON,
+			sortOrder: sortByBillableTime,
+			want: `[
+  {
+    "owner": "some-org",
+    "repo": "api",
+    "workflow": "release, nightly",
+    "id": 1,
+    "platform": "MACOS",
+    "billable_time_seconds": 600
+  },
+  {
+    "owner": "some-org",
+    "repo": "web",
+    "workflow": "ci",
+    "id": 2,
+    "platform": "UBUNTU",
+    "billable_time_seconds": 90
+  },
+  {
+    "owner": "some-org",
+    "repo": "api",
+    "workflow": "release, nightly",
+    "id": 1,
+    "platform": "UBUNTU",
+    "billable_time_seconds": 30
+  }
+]
+`,
+		},
+	} {
+		t.Run(testCase.desc, func(t *testing.T) {
+			var out bytes.Buffer
+
+			rows := usageRows(&testUsage, nil)
+			sortUsageRows(rows, testCase.sortOrder)
+
+			err := writeUsageRows(&out, testCase.format, rows)
+			require.NoError(t, err)
+
+			assert.Equal(t, testCase.want, out.String())
+		})
+	}
+}
+
+func TestWriteUsageRows_EmptyJSON(t *testing.T) {
+	var out bytes.Buffer
+
+	err := writeUsageRows(&out, formatJSON, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "[]\n", out.String())
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jlevesy/workflows-exporter/actions"
+)
+
+const (
+	columnOwner    = "owner"
+	columnRepo     = "repo"
+	columnWorkflow = "workflow"
+	columnPlatform = "platform"
+)
+
+var groupColumns = []string{columnOwner, columnRepo, columnWorkflow, columnPlatform}
+
+// reportRow is the total billable time of a group of usage rows.
+type reportRow struct {
+	columns      []string
+	group        []string
+	billableTime time.Duration
+	percent      float64
+}
+
+// MarshalJSON keeps the group columns first, in the order they were given.
+func (r reportRow) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+
+	for i, column := range r.columns {
+		key, _ := json.Marshal(column)
+		value, _ := json.Marshal(r.group[i])
+
+		fmt.Fprintf(&buf, "%s:%s,", key, value)
+	}
+
+	fmt.Fprintf(
+		&buf,
+		`"billable_time_seconds":%s,"percent":%s}`,
+		strconv.FormatFloat(r.billableTime.Seconds(), 'f', -1, 64),
+		strconv.FormatFloat(r.percent, 'f', -1, 64),
+	)
+
+	return buf.Bytes(), nil
+}
+
+func (r reportRow) values() []string {
+	return append(
+		slices.Clone(r.group),
+		strconv.FormatFloat(r.billableTime.Seconds(), 'f', -1, 64),
+		strconv.FormatFloat(r.percent, 'f', -1, 64),
+	)
+}
+
+func (r reportRow) display() []string {
+	return append(
+		slices.Clone(r.group),
+		r.billableTime.String(),
+		strconv.FormatFloat(r.percent, 'f', 2, 64)+"%",
+	)
+}
+
+// parseGroupBy validates a comma separated list of columns to group the usage by.
+func parseGroupBy(v string) ([]string, error) {
+	columns := actions.SplitList(v)
+
+	for i, column := range columns {
+		if !slices.Contains(groupColumns, column) {
+			return nil, fmt.Errorf("unsupported column %q, must be one of %s", column, strings.Join(groupColumns, ", "))
+		}
+
+		if slices.Contains(columns[:i], column) {
+			return nil, fmt.Errorf("column %q is listed more than once", column)
+		}
+	}
+
+	return columns, nil
+}
+
+// reportRows sums the billable time of the rows sharing the same values for the given columns, from the largest
+// to the smallest total. Only the top entries are kept if top is greater than zero, percentages are computed
+// over the total billable time of all the rows.
+func reportRows(rows []usageRow, columns []string, top int) []reportRow {
+	var (
+		total  time.Duration
+		report []reportRow
+		groups = make(map[string]int)
+	)
+
+	for _, row := range rows {
+		total += row.BillableTime
+
+		group := make([]string, len(columns))
+		for i, column := range columns {
+			group[i] = row.column(column)
+		}
+
+		key := strings.Join(group, "\x00")
+
+		idx, ok := groups[key]
+		if !ok {
+			idx = len(report)
+			groups[key] = idx
+			report = append(report, reportRow{columns: columns, group: group})
+		}
+
+		report[idx].billableTime += row.BillableTime
+	}
+
+	for i := range report {
+		if total > 0 {
+			report[i].percent = math.Round(float64(report[i].billableTime)/float64(total)*10000) / 100
+		}
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].billableTime != report[j].billableTime {
+			return report[i].billableTime > report[j].billableTime
+		}
+
+		return slices.Compare(report[i].group, report[j].group) < 0
+	})
+
+	if top > 0 && len(report) > top {
+		report = report[:top]
+	}
+
+	return report
+}
+
+func writeReportRows(w io.Writer, format string, columns []string, rows []reportRow) error {
+	var (
+		csvColumns = append(slices.Clone(columns), "billable_time_seconds", "percent")
+		headers    = make([]string, 0, len(columns)+2)
+		records    = make([]record, len(rows))
+	)
+
+	for _, column := range columns {
+		headers = append(headers, strings.ToUpper(column))
+	}
+
+	headers = append(headers, "BILLABLE TIME", "PERCENT")
+
+	for i, row := range rows {
+		records[i] = row
+	}
+
+	return writeRecords(w, format, csvColumns, headers, records)
+}
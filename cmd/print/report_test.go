@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportRows(t *testing.T) {
+	for _, testCase := range []struct {
+		desc      string
+		groupBy   string
+		platforms []string
+		top       int
+		format    string
+		want      string
+	}{
+		{
+			desc:    "per repo",
+			groupBy: "owner,repo",
+			format:  formatTable,
+			want: `OWNER     REPO  BILLABLE TIME  PERCENT
+some-org  api   10m30s         87.50%
+some-org  web   1m30s          12.50%
+`,
+		},
+		{
+			desc:    "top platform",
+			groupBy: "platform",
+			top:     1,
+			format:  formatCSV,
+			want: `platform,billable_time_seconds,percent
+MACOS,600,83.33
+`,
+		},
+		{
+			desc:      "per workflow on some platforms",
+			groupBy:   "repo,workflow",
+			platforms: []string{"ubuntu"},
+			format:    formatNDJSON,
+			want: `{"repo":"web","workflow":"ci","billable_time_seconds":90,"percent":75}
+{"repo":"api","workflow":"release, nightly","billable_time_seconds":30,"percent":25}
+`,
+		},
+	} {
+		t.Run(testCase.desc, func(t *testing.T) {
+			var out bytes.Buffer
+
+			columns, err := parseGroupBy(testCase.groupBy)
+			require.NoError(t, err)
+
+			report := reportRows(usageRows(&testUsage, testCase.platforms), columns, testCase.top)
+
+			err = writeReportRows(&out, testCase.format, columns, report)
+			require.NoError(t, err)
+
+			assert.Equal(t, testCase.want, out.String())
+		})
+	}
+}
+
+func TestParseGroupBy_Errors(t *testing.T) {
+	_, err := parseGroupBy("repo,id")
+	assert.EqualError(t, err, `unsupported column "id", must be one of owner, repo, workflow, platform`)
+
+	_, err = parseGroupBy("repo, repo")
+	assert.EqualError(t, err, `column "repo" is listed more than once`)
+}
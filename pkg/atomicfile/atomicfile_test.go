@@ -0,0 +1,52 @@
+package atomicfile_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jlevesy/workflows-exporter/pkg/atomicfile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFile(t *testing.T) {
+	for _, testCase := range []struct {
+		desc     string
+		existing []byte
+	}{
+		{
+			desc: "creates the file",
+		},
+		{
+			desc:     "replaces the existing file",
+			existing: []byte("previous content which is longer"),
+		},
+	} {
+		t.Run(testCase.desc, func(t *testing.T) {
+			var (
+				dir  = t.TempDir()
+				path = filepath.Join(dir, "state.json")
+			)
+
+			if testCase.existing != nil {
+				require.NoError(t, os.WriteFile(path, testCase.existing, 0o600))
+			}
+
+			require.NoError(t, atomicfile.WriteFile(path, []byte("content")))
+
+			got, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.Equal(t, []byte("content"), got)
+
+			// No temporary file is left behind.
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+		})
+	}
+}
+
+func TestWriteFile_MissingDir(t *testing.T) {
+	err := atomicfile.WriteFile(filepath.Join(t.TempDir(), "missing", "state.json"), []byte("content"))
+	require.Error(t, err)
+}
@@ -0,0 +1,174 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// GitHub rejects JWTs living more than 10 minutes.
+	appJWTLifetime = 9 * time.Minute
+	// Account for clock drift between us and GitHub.
+	appJWTClockSkew = time.Minute
+	// Refresh installation tokens a bit before they actually expire.
+	installationTokenEarlyExpiry = 5 * time.Minute
+)
+
+type AppConfig struct {
+	AppID      int64
+	PrivateKey *rsa.PrivateKey
+}
+
+func LoadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParsePrivateKey(raw)
+}
+
+// ParsePrivateKey decodes a PEM encoded RSA private key, as generated by GitHub (PKCS1) or as PKCS8.
+func ParsePrivateKey(raw []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("no PEM block found in private key")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse private key: %w", err)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+
+	return rsaKey, nil
+}
+
+// appTransport authenticates requests as the GitHub App itself, using a short lived JWT.
+type appTransport struct {
+	next    http.RoundTripper
+	config  AppConfig
+	nowFunc func() time.Time
+}
+
+func (t *appTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := signAppJWT(t.config, t.nowFunc())
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return t.next.RoundTrip(req)
+}
+
+func signAppJWT(config AppConfig, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-appJWTClockSkew).Unix(),
+		"exp": now.Add(appJWTLifetime).Unix(),
+		"iss": strconv.FormatInt(config.AppID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, config.PrivateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func newAppClient(config AppConfig, transport http.RoundTripper, baseURL string) (*github.Client, error) {
+	client := github.NewClient(
+		&http.Client{
+			Transport: &appTransport{
+				next:    transport,
+				config:  config,
+				nowFunc: time.Now,
+			},
+		},
+	)
+
+	if baseURL == "" {
+		return client, nil
+	}
+
+	return client.WithEnterpriseURLs(baseURL, baseURL)
+}
+
+// installationTokenSource mints installation access tokens on demand.
+type installationTokenSource struct {
+	ctx            context.Context
+	apps           *github.AppsService
+	installationID int64
+}
+
+func (s *installationTokenSource) Token() (*oauth2.Token, error) {
+	token, _, err := s.apps.CreateInstallationToken(s.ctx, s.installationID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create installation token for installation %d: %w", s.installationID, err)
+	}
+
+	return &oauth2.Token{
+		AccessToken: token.GetToken(),
+		TokenType:   "Bearer",
+		Expiry:      token.GetExpiresAt().Time,
+	}, nil
+}
+
+func newInstallationTokenSource(ctx context.Context, apps *github.AppsService, installationID int64) oauth2.TokenSource {
+	return oauth2.ReuseTokenSourceWithExpiry(
+		nil,
+		&installationTokenSource{
+			ctx:            ctx,
+			apps:           apps,
+			installationID: installationID,
+		},
+		installationTokenEarlyExpiry,
+	)
+}
+
+func findOrgInstallationID(ctx context.Context, apps *github.AppsService, org string) (int64, error) {
+	installation, _, err := apps.FindOrganizationInstallation(ctx, org)
+	if err != nil {
+		return 0, fmt.Errorf("could not find an installation of the app for organization %q: %w", org, err)
+	}
+
+	return installation.GetID(), nil
+}
@@ -0,0 +1,172 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstallationTokenSource(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var (
+		tokenCalls atomic.Int32
+		config     = AppConfig{AppID: 1234, PrivateKey: privateKey}
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/orgs/totocorp/installation", func(w http.ResponseWriter, r *http.Request) {
+		if !assertValidAppJWT(t, r, &privateKey.PublicKey, "1234") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"id": 42}`))
+	})
+	mux.HandleFunc("/api/v3/app/installations/42/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+		if !assertValidAppJWT(t, r, &privateKey.PublicKey, "1234") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		assert.Equal(t, http.MethodPost, r.Method)
+
+		tokenCalls.Add(1)
+
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"token":      "ghs_installation_token",
+			"expires_at": time.Now().Add(time.Hour).Format(time.RFC3339),
+		})
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	appClient, err := newAppClient(config, http.DefaultTransport, srv.URL)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	installationID, err := findOrgInstallationID(ctx, appClient.Apps, "totocorp")
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), installationID)
+
+	tokenSource := newInstallationTokenSource(ctx, appClient.Apps, installationID)
+
+	for i := 0; i < 3; i++ {
+		token, err := tokenSource.Token()
+		require.NoError(t, err)
+		assert.Equal(t, "ghs_installation_token", token.AccessToken)
+	}
+
+	// Token is still valid, it should have been minted only once.
+	assert.Equal(t, int32(1), tokenCalls.Load())
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+
+	for _, testCase := range []struct {
+		desc    string
+		raw     []byte
+		wantErr bool
+	}{
+		{
+			desc: "PKCS1",
+			raw: pem.EncodeToMemory(&pem.Block{
+				Type:  "RSA PRIVATE KEY",
+				Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+			}),
+		},
+		{
+			desc: "PKCS8",
+			raw: pem.EncodeToMemory(&pem.Block{
+				Type:  "PRIVATE KEY",
+				Bytes: pkcs8,
+			}),
+		},
+		{
+			desc:    "not PEM",
+			raw:     []byte("nope"),
+			wantErr: true,
+		},
+	} {
+		t.Run(testCase.desc, func(t *testing.T) {
+			got, err := ParsePrivateKey(testCase.raw)
+			if testCase.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.True(t, privateKey.Equal(got))
+		})
+	}
+}
+
+func assertValidAppJWT(t *testing.T, r *http.Request, publicKey *rsa.PublicKey, wantIssuer string) bool {
+	t.Helper()
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !assert.True(t, ok, "missing bearer token") {
+		return false
+	}
+
+	parts := strings.Split(token, ".")
+	if !assert.Len(t, parts, 3) {
+		return false
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if !assert.NoError(t, err) {
+		return false
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !assert.NoError(t, rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature)) {
+		return false
+	}
+
+	rawClaims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if !assert.NoError(t, err) {
+		return false
+	}
+
+	var claims struct {
+		Issuer    string `json:"iss"`
+		IssuedAt  int64  `json:"iat"`
+		ExpiresAt int64  `json:"exp"`
+	}
+
+	if !assert.NoError(t, json.Unmarshal(rawClaims, &claims)) {
+		return false
+	}
+
+	now := time.Now().Unix()
+
+	return assert.Equal(t, wantIssuer, claims.Issuer) &&
+		assert.LessOrEqual(t, claims.IssuedAt, now) &&
+		assert.Greater(t, claims.ExpiresAt, now) &&
+		assert.LessOrEqual(t, claims.ExpiresAt-claims.IssuedAt, int64(10*time.Minute/time.Second))
+}
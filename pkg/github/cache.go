@@ -0,0 +1,328 @@
+package github
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jlevesy/workflows-exporter/pkg/atomicfile"
+	"go.uber.org/zap"
+)
+
+const (
+	cacheResultHit  = "hit"
+	cacheResultMiss = "miss"
+)
+
+// Cache stores raw HTTP responses, keyed by request URL.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte)
+}
+
+type CacheConfig struct {
+	// MaxMemory caps the total size in bytes of the responses kept in memory, the least recently used ones are evicted first.
+	MaxMemory int64
+	// MaxAge is how long the files of a disk cache are kept since they were last written or read.
+	MaxAge time.Duration
+}
+
+var DefaultCacheConfig = CacheConfig{
+	MaxMemory: 64 << 20,
+	MaxAge:    7 * 24 * time.Hour,
+}
+
+// MemoryCache is a LRU cache bounded by the total size of its entries.
+type MemoryCache struct {
+	maxBytes int64
+
+	mu      sync.Mutex
+	size    int64
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key   string
+	value []byte
+}
+
+// NewMemoryCache returns a cache holding at most maxBytes of responses, unbounded if maxBytes is 0.
+func NewMemoryCache(maxBytes int64) *MemoryCache {
+	return &MemoryCache{
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	c.lru.MoveToFront(elem)
+
+	return elem.Value.(*memoryEntry).value, true
+}
+
+func (c *MemoryCache) Set(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+
+	if c.maxBytes > 0 && int64(len(value)) > c.maxBytes {
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&memoryEntry{key: key, value: value})
+	c.size += int64(len(value))
+
+	for c.maxBytes > 0 && c.size > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *MemoryCache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*memoryEntry)
+
+	delete(c.entries, entry.key)
+	c.size -= int64(len(entry.value))
+}
+
+// DiskCache keeps entries in memory, backed by files stored in a directory
+// so that they survive restarts. Files not used for the configured max age are pruned.
+type DiskCache struct {
+	memory *MemoryCache
+	dir    string
+	maxAge time.Duration
+	logger *zap.Logger
+
+	pruneMu   sync.Mutex
+	lastPrune time.Time
+}
+
+func NewDiskCache(dir string, config CacheConfig, logger *zap.Logger) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	c := DiskCache{
+		memory: NewMemoryCache(config.MaxMemory),
+		dir:    dir,
+		maxAge: config.MaxAge,
+		logger: logger,
+	}
+
+	c.prune()
+
+	return &c, nil
+}
+
+func (c *DiskCache) Get(key string) ([]byte, bool) {
+	if value, ok := c.memory.Get(key); ok {
+		return value, true
+	}
+
+	path := c.path(key)
+
+	value, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, false
+	case err != nil:
+		c.logger.Warn("Could not read cache entry", zap.String("key", key), zap.Error(err))
+		return nil, false
+	}
+
+	// Reading the entry counts as using it, for it not to be pruned.
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		c.logger.Warn("Could not touch cache entry", zap.String("key", key), zap.Error(err))
+	}
+
+	c.memory.Set(key, value)
+
+	return value, true
+}
+
+func (c *DiskCache) Set(key string, value []byte) {
+	c.memory.Set(key, value)
+
+	if err := atomicfile.WriteFile(c.path(key), value); err != nil {
+		c.logger.Warn("Could not write cache entry", zap.String("key", key), zap.Error(err))
+	}
+
+	c.maybePrune()
+}
+
+// maybePrune prunes the directory at most once per max age.
+func (c *DiskCache) maybePrune() {
+	c.pruneMu.Lock()
+	due := c.maxAge > 0 && time.Since(c.lastPrune) >= c.maxAge
+	c.pruneMu.Unlock()
+
+	if due {
+		c.prune()
+	}
+}
+
+// prune removes the files which were not used for longer than the max age.
+func (c *DiskCache) prune() {
+	if c.maxAge <= 0 {
+		return
+	}
+
+	c.pruneMu.Lock()
+	defer c.pruneMu.Unlock()
+
+	c.lastPrune = time.Now()
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		c.logger.Warn("Could not list cache entries", zap.String("dir", c.dir), zap.Error(err))
+		return
+	}
+
+	var pruned int
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || time.Since(info.ModTime()) < c.maxAge {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(c.dir, entry.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			c.logger.Warn("Could not prune cache entry", zap.String("file", entry.Name()), zap.Error(err))
+			continue
+		}
+
+		pruned++
+	}
+
+	if pruned > 0 {
+		c.logger.Info("Pruned cache entries", zap.String("dir", c.dir), zap.Int("pruned", pruned))
+	}
+}
+
+func (c *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+// cacheTransport performs conditional requests using the ETag and Last-Modified
+// headers of previously seen responses. A 304 response is transparently replaced by
+// the cached one, GitHub does not count those against the primary rate limit.
+type cacheTransport struct {
+	next    http.RoundTripper
+	cache   Cache
+	metrics *Metrics
+	logger  *zap.Logger
+}
+
+func newCacheTransport(next http.RoundTripper, cache Cache, metrics *Metrics, logger *zap.Logger) *cacheTransport {
+	return &cacheTransport{
+		next:    next,
+		cache:   cache,
+		metrics: metrics,
+		logger:  logger,
+	}
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return t.next.RoundTrip(req)
+	}
+
+	key := req.URL.String()
+
+	cachedResp := t.lookup(key, req)
+	if cachedResp != nil {
+		req = req.Clone(req.Context())
+
+		if etag := cachedResp.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+
+		if lastModified := cachedResp.Header.Get("Last-Modified"); lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cachedResp != nil {
+		t.metrics.incCacheRequests(cacheResultHit)
+
+		// Keep the cached payload, but report up to date rate limit information.
+		for name, values := range resp.Header {
+			if name == "Content-Length" || name == "Content-Type" {
+				continue
+			}
+
+			cachedResp.Header[name] = values
+		}
+
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		return cachedResp, nil
+	}
+
+	t.metrics.incCacheRequests(cacheResultMiss)
+
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	if resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "" {
+		return resp, nil
+	}
+
+	// DumpResponse restores the body after reading it.
+	dump, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		t.logger.Warn("Could not cache response", zap.String("url", key), zap.Error(err))
+		return resp, nil
+	}
+
+	t.cache.Set(key, dump)
+
+	return resp, nil
+}
+
+func (t *cacheTransport) lookup(key string, req *http.Request) *http.Response {
+	raw, ok := t.cache.Get(key)
+	if !ok {
+		return nil
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(raw)), req)
+	if err != nil {
+		t.logger.Warn("Could not read cached response", zap.String("url", key), zap.Error(err))
+		return nil
+	}
+
+	return resp
+}
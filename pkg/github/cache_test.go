@@ -0,0 +1,164 @@
+package github
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestCacheTransport(t *testing.T) {
+	for _, testCase := range []struct {
+		desc     string
+		newCache func(t *testing.T, logger *zap.Logger) func() Cache
+	}{
+		{
+			desc: "memory",
+			newCache: func(_ *testing.T, _ *zap.Logger) func() Cache {
+				cache := NewMemoryCache(0)
+				return func() Cache { return cache }
+			},
+		},
+		{
+			desc: "disk, survives restarts",
+			newCache: func(t *testing.T, logger *zap.Logger) func() Cache {
+				dir := t.TempDir()
+
+				return func() Cache {
+					cache, err := NewDiskCache(dir, DefaultCacheConfig, logger)
+					require.NoError(t, err)
+					return cache
+				}
+			},
+		},
+	} {
+		t.Run(testCase.desc, func(t *testing.T) {
+			var (
+				calls  atomic.Int32
+				logger = zaptest.NewLogger(t)
+			)
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				call := calls.Add(1)
+
+				w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(100-int(call)))
+
+				if r.Header.Get("If-None-Match") == `"v1"` {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+
+				w.Header().Set("ETag", `"v1"`)
+				_, _ = w.Write([]byte(`{"name":"repo-A"}`))
+			}))
+			defer srv.Close()
+
+			var (
+				metrics  = NewMetrics()
+				newCache = testCase.newCache(t, logger)
+			)
+
+			for i, wantRemaining := range []string{"99", "98", "97"} {
+				// Recreate the cache every time, to make sure that
+				// the disk cache does not only rely on its memory layer.
+				transport := newCacheTransport(http.DefaultTransport, newCache(), metrics, logger)
+
+				req, err := http.NewRequest(http.MethodGet, srv.URL+"/repos", nil)
+				require.NoError(t, err)
+
+				resp, err := transport.RoundTrip(req)
+				require.NoError(t, err)
+
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				require.NoError(t, resp.Body.Close())
+
+				assert.Equal(t, http.StatusOK, resp.StatusCode, "request %d", i)
+				assert.Equal(t, `{"name":"repo-A"}`, string(body), "request %d", i)
+				assert.Equal(t, wantRemaining, resp.Header.Get("X-RateLimit-Remaining"), "request %d", i)
+			}
+
+			err := testutil.CollectAndCompare(
+				metrics,
+				strings.NewReader(`
+# HELP github_api_cache_requests_total Total of cacheable GitHub API requests, per result (hit or miss)
+# TYPE github_api_cache_requests_total counter
+github_api_cache_requests_total{result="hit"} 2
+github_api_cache_requests_total{result="miss"} 1
+`),
+			)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestMemoryCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewMemoryCache(10)
+
+	cache.Set("a", []byte("aaaa"))
+	cache.Set("b", []byte("bbbb"))
+
+	// Using a makes b the least recently used entry.
+	_, ok := cache.Get("a")
+	require.True(t, ok)
+
+	cache.Set("c", []byte("cccc"))
+
+	_, ok = cache.Get("b")
+	assert.False(t, ok)
+
+	for _, key := range []string{"a", "c"} {
+		_, ok = cache.Get(key)
+		assert.True(t, ok, key)
+	}
+
+	// Entries larger than the cache are not kept.
+	cache.Set("d", []byte("ddddddddddd"))
+
+	_, ok = cache.Get("d")
+	assert.False(t, ok)
+}
+
+func TestDiskCache_PrunesUnusedEntries(t *testing.T) {
+	var (
+		dir    = t.TempDir()
+		logger = zaptest.NewLogger(t)
+		config = CacheConfig{MaxAge: time.Hour}
+	)
+
+	cache, err := NewDiskCache(dir, config, logger)
+	require.NoError(t, err)
+
+	cache.Set("old", []byte("old"))
+	cache.Set("recent", []byte("recent"))
+
+	past := time.Now().Add(-2 * time.Hour)
+	err = os.Chtimes(cache.path("old"), past, past)
+	require.NoError(t, err)
+
+	// A new cache prunes the directory on startup.
+	cache, err = NewDiskCache(dir, config, logger)
+	require.NoError(t, err)
+
+	_, ok := cache.Get("old")
+	assert.False(t, ok)
+
+	value, ok := cache.Get("recent")
+	require.True(t, ok)
+	assert.Equal(t, "recent", string(value))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
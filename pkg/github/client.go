@@ -2,6 +2,8 @@ package github
 
 import (
 	"context"
+	"errors"
+	"net/http"
 
 	"github.com/gofri/go-github-ratelimit/github_ratelimit"
 	"github.com/google/go-github/v57/github"
@@ -9,15 +11,154 @@ import (
 	"golang.org/x/oauth2"
 )
 
-func NewClient(ctx context.Context, token string, logger *zap.Logger) (*github.Client, error) {
+type ClientOpt func(c *clientConfig)
+
+// WithRetry configures how transient errors are retried.
+func WithRetry(config RetryConfig) ClientOpt {
+	return func(c *clientConfig) {
+		c.retry = config
+	}
+}
+
+// WithMetrics instruments the client transports.
+func WithMetrics(metrics *Metrics) ClientOpt {
+	return func(c *clientConfig) {
+		c.metrics = metrics
+	}
+}
+
+// WithCache enables conditional requests, backed by the given cache.
+func WithCache(cache Cache) ClientOpt {
+	return func(c *clientConfig) {
+		c.cache = cache
+	}
+}
+
+// WithEnterpriseURLs targets a GitHub Enterprise Server instance instead of github.com.
+// The upload URL defaults to the base URL if empty.
+func WithEnterpriseURLs(baseURL, uploadURL string) ClientOpt {
+	return func(c *clientConfig) {
+		c.baseURL = baseURL
+		c.uploadURL = uploadURL
+	}
+}
+
+// WithTransport sets the HTTP transport used to reach GitHub.
+func WithTransport(transport http.RoundTripper) ClientOpt {
+	return func(c *clientConfig) {
+		c.transport = transport
+	}
+}
+
+type clientConfig struct {
+	retry   RetryConfig
+	metrics *Metrics
+	cache   Cache
+
+	baseURL   string
+	uploadURL string
+	transport http.RoundTripper
+}
+
+type AuthConfig struct {
+	// Token is a personal access token, used when no app is configured.
+	Token string
+
+	// App authenticates as a GitHub App installation when App.AppID is set.
+	App AppConfig
+	// InstallationID is the installation to use for the app, discovered per organization if zero.
+	InstallationID int64
+}
+
+// NewOrgClients returns a client per organization. With a personal access token all organizations
+// share the same client, when authenticating as a GitHub App each organization gets a client
+// authenticated as the installation of the app in that organization.
+func NewOrgClients(ctx context.Context, auth AuthConfig, orgs []string, logger *zap.Logger, opts ...ClientOpt) (map[string]*github.Client, error) {
+	clients := make(map[string]*github.Client, len(orgs))
+
+	if auth.App.AppID == 0 {
+		client, err := NewClient(
+			ctx,
+			oauth2.StaticTokenSource(&oauth2.Token{AccessToken: auth.Token}),
+			logger,
+			opts...,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, org := range orgs {
+			clients[org] = client
+		}
+
+		return clients, nil
+	}
+
+	if auth.App.PrivateKey == nil {
+		return nil, errors.New("a private key is required to authenticate as a GitHub App")
+	}
+
+	if auth.InstallationID != 0 && len(orgs) > 1 {
+		return nil, errors.New("an installation ID can't be used to monitor several organizations, leave it empty to discover installations")
+	}
+
+	config := newClientConfig(opts)
+
+	appClient, err := newAppClient(
+		auth.App,
+		newRetryTransport(config.transport, config.retry, config.metrics, logger),
+		config.baseURL,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, org := range orgs {
+		installationID := auth.InstallationID
+		if installationID == 0 {
+			installationID, err = findOrgInstallationID(ctx, appClient.Apps, org)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		logger.Info(
+			"Authenticating as a GitHub App installation",
+			zap.String("owner", org),
+			zap.Int64("app_id", auth.App.AppID),
+			zap.Int64("installation_id", installationID),
+		)
+
+		clients[org], err = NewClient(
+			ctx,
+			newInstallationTokenSource(ctx, appClient.Apps, installationID),
+			logger,
+			opts...,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return clients, nil
+}
+
+func NewClient(ctx context.Context, tokenSource oauth2.TokenSource, logger *zap.Logger, opts ...ClientOpt) (*github.Client, error) {
+	config := newClientConfig(opts)
+
 	tc := oauth2.NewClient(
-		ctx,
-		oauth2.StaticTokenSource(
-			&oauth2.Token{AccessToken: token},
-		),
+		context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: config.transport}),
+		tokenSource,
 	)
+
+	var transport http.RoundTripper = newRetryTransport(tc.Transport, config.retry, config.metrics, logger)
+
+	if config.cache != nil {
+		transport = newCacheTransport(transport, config.cache, config.metrics, logger)
+	}
+
 	rateLimiter, err := github_ratelimit.NewRateLimitWaiterClient(
-		tc.Transport,
+		transport,
 		github_ratelimit.WithLimitDetectedCallback(
 			func(ctx *github_ratelimit.CallbackContext) {
 				logger.Error(
@@ -32,5 +173,28 @@ func NewClient(ctx context.Context, token string, logger *zap.Logger) (*github.C
 		return nil, err
 	}
 
-	return github.NewClient(rateLimiter), nil
+	client := github.NewClient(rateLimiter)
+
+	if config.baseURL == "" {
+		return client, nil
+	}
+
+	return client.WithEnterpriseURLs(config.baseURL, config.uploadURL)
+}
+
+func newClientConfig(opts []ClientOpt) clientConfig {
+	config := clientConfig{
+		retry:     DefaultRetryConfig,
+		transport: http.DefaultTransport,
+	}
+
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	if config.uploadURL == "" {
+		config.uploadURL = config.baseURL
+	}
+
+	return config
 }
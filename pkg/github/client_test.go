@@ -0,0 +1,77 @@
+package github
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"golang.org/x/oauth2"
+)
+
+func TestNewClient_Enterprise(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/totocorp/repo-A" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		assert.Equal(t, "Bearer some-token", r.Header.Get("Authorization"))
+
+		_, _ = w.Write([]byte(`{"name":"repo-A"}`))
+	}))
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	err := os.WriteFile(
+		caFile,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}),
+		0o600,
+	)
+	require.NoError(t, err)
+
+	for _, testCase := range []struct {
+		desc    string
+		config  TransportConfig
+		wantErr bool
+	}{
+		{
+			desc:   "trusts the custom CA",
+			config: TransportConfig{CAFile: caFile},
+		},
+		{
+			desc:    "rejects unknown CA",
+			wantErr: true,
+		},
+	} {
+		t.Run(testCase.desc, func(t *testing.T) {
+			transport, err := NewTransport(testCase.config)
+			require.NoError(t, err)
+
+			client, err := NewClient(
+				context.Background(),
+				oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "some-token"}),
+				zaptest.NewLogger(t),
+				WithEnterpriseURLs(srv.URL, ""),
+				WithTransport(transport),
+				WithRetry(RetryConfig{}),
+			)
+			require.NoError(t, err)
+
+			repo, _, err := client.Repositories.Get(context.Background(), "totocorp", "repo-A")
+			if testCase.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "repo-A", repo.GetName())
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package github
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the instrumentation of the GitHub client transports.
+// A single instance can be shared between several clients.
+type Metrics struct {
+	retries          *prometheus.CounterVec
+	retriesExhausted *prometheus.CounterVec
+	cacheRequests    *prometheus.CounterVec
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		retries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "github_api_retries_total",
+				Help: "Total of GitHub API requests retried, per reason",
+			},
+			[]string{"reason"},
+		),
+		retriesExhausted: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "github_api_retries_exhausted_total",
+				Help: "Total of GitHub API requests that failed after exhausting all retries, per reason",
+			},
+			[]string{"reason"},
+		),
+		cacheRequests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "github_api_cache_requests_total",
+				Help: "Total of cacheable GitHub API requests, per result (hit or miss)",
+			},
+			[]string{"result"},
+		),
+	}
+}
+
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.retries.Describe(ch)
+	m.retriesExhausted.Describe(ch)
+	m.cacheRequests.Describe(ch)
+}
+
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.retries.Collect(ch)
+	m.retriesExhausted.Collect(ch)
+	m.cacheRequests.Collect(ch)
+}
+
+func (m *Metrics) incRetries(reason string) {
+	if m == nil {
+		return
+	}
+
+	m.retries.WithLabelValues(reason).Inc()
+}
+
+func (m *Metrics) incRetriesExhausted(reason string) {
+	if m == nil {
+		return
+	}
+
+	m.retriesExhausted.WithLabelValues(reason).Inc()
+}
+
+func (m *Metrics) incCacheRequests(result string) {
+	if m == nil {
+		return
+	}
+
+	m.cacheRequests.WithLabelValues(result).Inc()
+}
@@ -0,0 +1,185 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	retryReasonNetwork     = "network_error"
+	retryReasonServerError = "server_error"
+	retryReasonTooMany     = "too_many_requests"
+)
+
+type RetryConfig struct {
+	// MaxRetries is the maximum amount of retries for a single request, 0 disables retries.
+	MaxRetries int
+	// BaseDelay is the delay before the first retry, doubled on every following attempt.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between two attempts, including delays asked by a Retry-After header.
+	MaxDelay time.Duration
+}
+
+var DefaultRetryConfig = RetryConfig{
+	MaxRetries: 3,
+	BaseDelay:  500 * time.Millisecond,
+	MaxDelay:   30 * time.Second,
+}
+
+type retryTransport struct {
+	next    http.RoundTripper
+	config  RetryConfig
+	metrics *Metrics
+	logger  *zap.Logger
+
+	sleep  func(context.Context, time.Duration) error
+	jitter func(time.Duration) time.Duration
+}
+
+func newRetryTransport(next http.RoundTripper, config RetryConfig, metrics *Metrics, logger *zap.Logger) *retryTransport {
+	return &retryTransport{
+		next:    next,
+		config:  config,
+		metrics: metrics,
+		logger:  logger,
+		sleep:   sleepContext,
+		jitter:  equalJitter,
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isIdempotent(req.Method) {
+		return t.next.RoundTrip(req)
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+
+		reason, retryable := retryReason(resp, err)
+		if !retryable {
+			return resp, err
+		}
+
+		if attempt >= t.config.MaxRetries {
+			t.metrics.incRetriesExhausted(reason)
+			return resp, err
+		}
+
+		delay := t.backoff(attempt, resp)
+
+		t.logger.Warn(
+			"Retrying GitHub API request",
+			zap.String("method", req.Method),
+			zap.String("url", req.URL.String()),
+			zap.String("reason", reason),
+			zap.Int("attempt", attempt+1),
+			zap.Duration("delay", delay),
+			zap.Error(err),
+		)
+
+		if resp != nil {
+			// Drain the body to allow the connection to be reused.
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		t.metrics.incRetries(reason)
+
+		if err := t.sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (t *retryTransport) backoff(attempt int, resp *http.Response) time.Duration {
+	delay := t.config.BaseDelay << attempt
+	if delay <= 0 || delay > t.config.MaxDelay {
+		delay = t.config.MaxDelay
+	}
+
+	delay = t.jitter(delay)
+
+	if retryAfter, ok := parseRetryAfter(resp); ok && retryAfter > delay {
+		delay = min(retryAfter, t.config.MaxDelay)
+	}
+
+	return delay
+}
+
+func retryReason(resp *http.Response, err error) (string, bool) {
+	switch {
+	case err != nil:
+		// Do not retry if the request has been cancelled on purpose.
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return "", false
+		}
+
+		return retryReasonNetwork, true
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return retryReasonTooMany, true
+	case resp.StatusCode >= 500:
+		return retryReasonServerError, true
+	default:
+		return "", false
+	}
+}
+
+func parseRetryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date), true
+	}
+
+	return 0, false
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// equalJitter keeps half of the delay and randomizes the other half,
+// so that concurrent retries spread without collapsing to zero.
+func equalJitter(d time.Duration) time.Duration {
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
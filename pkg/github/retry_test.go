@@ -0,0 +1,180 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestRetryTransport(t *testing.T) {
+	for _, testCase := range []struct {
+		desc        string
+		method      string
+		maxDelay    time.Duration
+		responses   []func(w http.ResponseWriter)
+		wantStatus  int
+		wantCalls   int32
+		wantDelays  []time.Duration
+		wantMetrics string
+	}{
+		{
+			desc:   "retries server errors until success",
+			method: http.MethodGet,
+			responses: []func(w http.ResponseWriter){
+				writeStatus(http.StatusBadGateway),
+				writeStatus(http.StatusServiceUnavailable),
+				writeStatus(http.StatusOK),
+			},
+			wantStatus: http.StatusOK,
+			wantCalls:  3,
+			wantDelays: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond},
+			wantMetrics: `
+# HELP github_api_retries_total Total of GitHub API requests retried, per reason
+# TYPE github_api_retries_total counter
+github_api_retries_total{reason="server_error"} 2
+`,
+		},
+		{
+			desc:   "gives up after max retries",
+			method: http.MethodGet,
+			responses: []func(w http.ResponseWriter){
+				writeStatus(http.StatusBadGateway),
+				writeStatus(http.StatusBadGateway),
+				writeStatus(http.StatusBadGateway),
+				writeStatus(http.StatusBadGateway),
+			},
+			wantStatus: http.StatusBadGateway,
+			wantCalls:  4,
+			wantDelays: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond},
+			wantMetrics: `
+# HELP github_api_retries_exhausted_total Total of GitHub API requests that failed after exhausting all retries, per reason
+# TYPE github_api_retries_exhausted_total counter
+github_api_retries_exhausted_total{reason="server_error"} 1
+# HELP github_api_retries_total Total of GitHub API requests retried, per reason
+# TYPE github_api_retries_total counter
+github_api_retries_total{reason="server_error"} 3
+`,
+		},
+		{
+			desc:     "caps the delay",
+			method:   http.MethodGet,
+			maxDelay: 150 * time.Millisecond,
+			responses: []func(w http.ResponseWriter){
+				writeStatus(http.StatusBadGateway),
+				writeStatus(http.StatusBadGateway),
+				writeStatus(http.StatusBadGateway),
+				writeStatus(http.StatusOK),
+			},
+			wantStatus: http.StatusOK,
+			wantCalls:  4,
+			wantDelays: []time.Duration{100 * time.Millisecond, 150 * time.Millisecond, 150 * time.Millisecond},
+			wantMetrics: `
+# HELP github_api_retries_total Total of GitHub API requests retried, per reason
+# TYPE github_api_retries_total counter
+github_api_retries_total{reason="server_error"} 3
+`,
+		},
+		{
+			desc:   "respects retry-after",
+			method: http.MethodGet,
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("Retry-After", "2")
+					w.WriteHeader(http.StatusTooManyRequests)
+				},
+				writeStatus(http.StatusOK),
+			},
+			wantStatus: http.StatusOK,
+			wantCalls:  2,
+			wantDelays: []time.Duration{2 * time.Second},
+			wantMetrics: `
+# HELP github_api_retries_total Total of GitHub API requests retried, per reason
+# TYPE github_api_retries_total counter
+github_api_retries_total{reason="too_many_requests"} 1
+`,
+		},
+		{
+			desc:   "does not retry client errors",
+			method: http.MethodGet,
+			responses: []func(w http.ResponseWriter){
+				writeStatus(http.StatusNotFound),
+			},
+			wantStatus: http.StatusNotFound,
+			wantCalls:  1,
+		},
+		{
+			desc:   "does not retry non idempotent requests",
+			method: http.MethodPost,
+			responses: []func(w http.ResponseWriter){
+				writeStatus(http.StatusBadGateway),
+			},
+			wantStatus: http.StatusBadGateway,
+			wantCalls:  1,
+		},
+	} {
+		t.Run(testCase.desc, func(t *testing.T) {
+			var calls atomic.Int32
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				call := calls.Add(1)
+				testCase.responses[call-1](w)
+			}))
+			defer srv.Close()
+
+			maxDelay := testCase.maxDelay
+			if maxDelay == 0 {
+				maxDelay = 10 * time.Second
+			}
+
+			var (
+				gotDelays []time.Duration
+				metrics   = NewMetrics()
+				transport = newRetryTransport(
+					http.DefaultTransport,
+					RetryConfig{
+						MaxRetries: 3,
+						BaseDelay:  100 * time.Millisecond,
+						MaxDelay:   maxDelay,
+					},
+					metrics,
+					zaptest.NewLogger(t),
+				)
+			)
+
+			transport.jitter = func(d time.Duration) time.Duration { return d }
+			transport.sleep = func(_ context.Context, d time.Duration) error {
+				gotDelays = append(gotDelays, d)
+				return nil
+			}
+
+			req, err := http.NewRequest(testCase.method, srv.URL, nil)
+			require.NoError(t, err)
+
+			resp, err := transport.RoundTrip(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, testCase.wantStatus, resp.StatusCode)
+			assert.Equal(t, testCase.wantCalls, calls.Load())
+			assert.Equal(t, testCase.wantDelays, gotDelays)
+
+			err = testutil.CollectAndCompare(metrics, strings.NewReader(testCase.wantMetrics))
+			require.NoError(t, err)
+		})
+	}
+}
+
+func writeStatus(code int) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.WriteHeader(code)
+	}
+}